@@ -0,0 +1,166 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// autoAbsenceNote marks the absences markAbsent records.
+const autoAbsenceNote = "Marked absent automatically"
+
+// absenceChecked remembers which user days markAbsences has already
+// handled, keyed by date, so each minute only looks at new work.
+var absenceChecked = make(map[string]map[uint]bool)
+
+// markAbsences records users as absent once their workday has ended without
+// a check-in. It looks at the shift that started today and, for overnight
+// schedules, the one that started yesterday.
+func markAbsences(now time.Time) {
+	var users []User
+	if err := db.Find(&users).Error; err != nil {
+		log.Printf("mark-absences: failed to load users: %v", err)
+		return
+	}
+
+	for _, user := range users {
+		loc := userLocation(user)
+		local := now.In(loc)
+		schedule := scheduleFor(user)
+
+		for _, day := range []time.Time{local.AddDate(0, 0, -1), local} {
+			day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, loc)
+			key := day.Format(dateLayout)
+			if absenceChecked[key][user.ID] || local.Before(schedule.endOn(day)) {
+				continue
+			}
+
+			if _, err := markAbsent(db, user, schedule, day); err != nil {
+				log.Printf("mark-absences: user %d on %s: %v", user.ID, key, err)
+				continue
+			}
+			if absenceChecked[key] == nil {
+				absenceChecked[key] = make(map[uint]bool)
+			}
+			absenceChecked[key][user.ID] = true
+		}
+	}
+
+	// Forget days that can no longer be processed
+	cutoff := now.AddDate(0, 0, -3).Format(dateLayout)
+	for key := range absenceChecked {
+		if key < cutoff {
+			delete(absenceChecked, key)
+		}
+	}
+}
+
+// markAbsent creates an absent record for user on day, a midnight in the
+// user's timezone. Days that are not working days, holidays, leave days,
+// days before the account existed, days in an approved timesheet and days
+// that already have a record (including deleted ones) are skipped. It
+// reports whether a record was created.
+func markAbsent(tx *gorm.DB, user User, schedule WorkSchedule, day time.Time) (bool, error) {
+	key := day.Format(dateLayout)
+
+	if !schedule.isWorkingDay(day) {
+		return false, nil
+	}
+	if key < user.CreatedAt.In(day.Location()).Format(dateLayout) {
+		return false, nil
+	}
+	if isHoliday(tx, user, key) || isPeriodLocked(user.ID, key, key) {
+		return false, nil
+	}
+
+	leave, err := approvedLeaveByDate(tx, user, day, day)
+	if err != nil {
+		return false, err
+	}
+	if leave[key] > 0 {
+		return false, nil
+	}
+
+	// A record deleted by an admin is not recreated
+	var existing int64
+	if err := tx.Unscoped().Model(&Attendance{}).Where("user_id = ? AND date = ?", user.ID, key).Count(&existing).Error; err != nil {
+		return false, err
+	}
+	if existing > 0 {
+		return false, nil
+	}
+
+	attendance := Attendance{
+		UserID: user.ID,
+		Date:   Date(key),
+		Status: "absent",
+		Notes:  autoAbsenceNote,
+	}
+	if err := tx.Create(&attendance).Error; err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// backfillAbsences marks absences for past days in a date range, for all
+// users or for one. Days whose shift has not ended yet are skipped.
+func backfillAbsences(c *gin.Context) {
+	var req BackfillAbsencesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	start, err := parseDate(req.StartDate, time.UTC)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start_date, expected YYYY-MM-DD"})
+		return
+	}
+	end, err := parseDate(req.EndDate, time.UTC)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end_date, expected YYYY-MM-DD"})
+		return
+	}
+	if end.Before(start) || end.Sub(start) > 366*24*time.Hour {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Date range must be ascending and at most a year"})
+		return
+	}
+
+	query := db.Model(&User{})
+	if req.UserID != nil {
+		query = query.Where("id = ?", *req.UserID)
+	}
+	var users []User
+	if err := query.Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
+		return
+	}
+
+	now := time.Now()
+	created := 0
+	for _, user := range users {
+		loc := userLocation(user)
+		schedule := scheduleFor(user)
+
+		for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+			day := time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, loc)
+			if now.Before(schedule.endOn(day)) {
+				continue
+			}
+
+			marked, err := markAbsent(db, user, schedule, day)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark absences"})
+				return
+			}
+			if marked {
+				created++
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"created": created})
+}
@@ -5,6 +5,7 @@ import (
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 func getAllUsers(c *gin.Context) {
@@ -67,34 +68,12 @@ func getAllAttendance(c *gin.Context) {
 		}
 	}
 
-	// Filter by user ID if provided
-	userIDParam := c.Query("user_id")
-	
-	// Filter by date range if provided
-	startDate := c.Query("start_date")
-	endDate := c.Query("end_date")
-
 	offset := (page - 1) * limit
 
 	var attendances []Attendance
 	var total int64
 
-	query := db.Model(&Attendance{})
-
-	// Apply filters
-	if userIDParam != "" {
-		if userID, err := strconv.ParseUint(userIDParam, 10, 32); err == nil {
-			query = query.Where("user_id = ?", userID)
-		}
-	}
-
-	if startDate != "" && endDate != "" {
-		query = query.Where("date BETWEEN ? AND ?", startDate, endDate)
-	} else if startDate != "" {
-		query = query.Where("date >= ?", startDate)
-	} else if endDate != "" {
-		query = query.Where("date <= ?", endDate)
-	}
+	query := filterAttendance(c, db.Model(&Attendance{}))
 
 	// Count total records with filters applied
 	query.Count(&total)
@@ -120,6 +99,30 @@ func getAllAttendance(c *gin.Context) {
 	})
 }
 
+// filterAttendance applies the user_id, start_date and end_date query
+// parameters shared by the attendance listing and export.
+func filterAttendance(c *gin.Context, query *gorm.DB) *gorm.DB {
+	// Filter by user ID if provided
+	if userIDParam := c.Query("user_id"); userIDParam != "" {
+		if userID, err := strconv.ParseUint(userIDParam, 10, 32); err == nil {
+			query = query.Where("attendances.user_id = ?", userID)
+		}
+	}
+
+	// Filter by date range if provided
+	startDate := c.Query("start_date")
+	endDate := c.Query("end_date")
+	if startDate != "" && endDate != "" {
+		query = query.Where("attendances.date BETWEEN ? AND ?", startDate, endDate)
+	} else if startDate != "" {
+		query = query.Where("attendances.date >= ?", startDate)
+	} else if endDate != "" {
+		query = query.Where("attendances.date <= ?", endDate)
+	}
+
+	return query
+}
+
 func updateUser(c *gin.Context) {
 	userID := c.Param("id")
 	
@@ -145,17 +148,40 @@ func updateUser(c *gin.Context) {
 	if req.Department != "" {
 		user.Department = req.Department
 	}
+	if req.Timezone != "" {
+		if err := validateTimezone(req.Timezone); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		user.Timezone = req.Timezone
+	}
+	roleChanged := false
 	if req.Role != "" {
 		// Validate role
-		if req.Role == "admin" || req.Role == "employee" {
+		if req.Role == "admin" || req.Role == "manager" || req.Role == "employee" {
+			roleChanged = req.Role != user.Role
 			user.Role = req.Role
 		} else {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role. Must be 'admin' or 'employee'"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role. Must be 'admin', 'manager' or 'employee'"})
 			return
 		}
 	}
 
-	if err := db.Save(&user).Error; err != nil {
+	// Only the edited columns are written, so that a password or token
+	// change committed meanwhile is not overwritten
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).
+			Select("name", "position", "department", "timezone", "role", "updated_at").
+			Updates(&user).Error; err != nil {
+			return err
+		}
+		// Tokens carrying the old role must not keep working
+		if roleChanged {
+			return invalidateTokens(tx, user.ID)
+		}
+		return nil
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
 		return
 	}
@@ -183,8 +209,18 @@ func deleteUser(c *gin.Context) {
 		}
 	}
 
-	// Soft delete the user (GORM will set deleted_at timestamp)
-	if err := db.Delete(&user).Error; err != nil {
+	// Soft delete the user (GORM will set deleted_at timestamp) and sign them out
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := invalidateTokens(tx, user.ID); err != nil {
+			return err
+		}
+		if err := tx.Delete(&user).Error; err != nil {
+			return err
+		}
+		_, err := revokeSessions(tx, user.ID)
+		return err
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
 		return
 	}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+)
+
+// alertRules hold the thresholds of the attendance pattern rules. A zero
+// threshold disables its rule.
+var alertRules = struct {
+	LateThreshold            int // alert when late more than this many times
+	LateWindowDays           int
+	WeekdayAbsenceThreshold  int // alert at this many absences on Mondays, or on Fridays
+	WeekdayAbsenceWindowDays int
+	DriftMinutesPerWeek      int // alert when check-ins get this much later each week
+	DriftWindowDays          int
+	DriftMinDays             int // check-ins needed to judge a drift
+}{
+	LateThreshold:            3,
+	LateWindowDays:           30,
+	WeekdayAbsenceThreshold:  3,
+	WeekdayAbsenceWindowDays: 90,
+	DriftMinutesPerWeek:      10,
+	DriftWindowDays:          30,
+	DriftMinDays:             10,
+}
+
+// driftMinFit is how well check-in times must follow a straight line, as
+// R², for a trend to count as steady rather than noise.
+const driftMinFit = 0.5
+
+// alertRule finds a pattern in one user's attendance between start and end,
+// both inclusive. It returns how often the pattern occurred, zero if not at
+// all, and a message describing it.
+type alertRule struct {
+	name       string
+	windowDays int
+	check      func(user User, start, end time.Time) (int, string, error)
+}
+
+// enabledAlertRules returns the rules whose threshold is set.
+func enabledAlertRules() []alertRule {
+	var rules []alertRule
+	if alertRules.LateThreshold > 0 && alertRules.LateWindowDays > 0 {
+		rules = append(rules, alertRule{"late_frequency", alertRules.LateWindowDays, checkLateFrequency})
+	}
+	if alertRules.WeekdayAbsenceThreshold > 0 && alertRules.WeekdayAbsenceWindowDays > 0 {
+		rules = append(rules,
+			alertRule{"monday_absences", alertRules.WeekdayAbsenceWindowDays, weekdayAbsences(time.Monday)},
+			alertRule{"friday_absences", alertRules.WeekdayAbsenceWindowDays, weekdayAbsences(time.Friday)})
+	}
+	if alertRules.DriftMinutesPerWeek > 0 && alertRules.DriftWindowDays > 0 {
+		rules = append(rules, alertRule{"check_in_drift", alertRules.DriftWindowDays, checkCheckInDrift})
+	}
+	return rules
+}
+
+func checkLateFrequency(user User, start, end time.Time) (int, string, error) {
+	var late int64
+	if err := db.Model(&Attendance{}).
+		Where("user_id = ? AND status = ? AND date BETWEEN ? AND ?", user.ID, "late", start.Format(dateLayout), end.Format(dateLayout)).
+		Count(&late).Error; err != nil {
+		return 0, "", err
+	}
+	if int(late) <= alertRules.LateThreshold {
+		return 0, "", nil
+	}
+	return int(late), fmt.Sprintf("Late %d times between %s and %s", late, start.Format(dateLayout), end.Format(dateLayout)), nil
+}
+
+// weekdayAbsences returns a rule counting absences on weekday, which
+// cluster around weekends when days off are taken unannounced.
+func weekdayAbsences(weekday time.Weekday) func(User, time.Time, time.Time) (int, string, error) {
+	return func(user User, start, end time.Time) (int, string, error) {
+		// SQLite numbers weekdays from Sunday, like time.Weekday
+		var absences int64
+		if err := db.Model(&Attendance{}).
+			Where("user_id = ? AND status = ? AND date BETWEEN ? AND ? AND strftime('%w', date) = ?",
+				user.ID, "absent", start.Format(dateLayout), end.Format(dateLayout), strconv.Itoa(int(weekday))).
+			Count(&absences).Error; err != nil {
+			return 0, "", err
+		}
+		if int(absences) < alertRules.WeekdayAbsenceThreshold {
+			return 0, "", nil
+		}
+		return int(absences), fmt.Sprintf("Absent on %d %ss between %s and %s",
+			absences, weekday, start.Format(dateLayout), end.Format(dateLayout)), nil
+	}
+}
+
+// checkCheckInDrift fits a line through the local check-in times of the
+// window and reports a steady trend towards later check-ins.
+func checkCheckInDrift(user User, start, end time.Time) (int, string, error) {
+	var records []Attendance
+	if err := db.Select("date", "check_in").
+		Where("user_id = ? AND check_in IS NOT NULL AND date BETWEEN ? AND ?", user.ID, start.Format(dateLayout), end.Format(dateLayout)).
+		Find(&records).Error; err != nil {
+		return 0, "", err
+	}
+	if len(records) < alertRules.DriftMinDays || len(records) < 2 {
+		return 0, "", nil
+	}
+
+	loc := userLocation(user)
+	days := make([]float64, len(records))
+	minutes := make([]float64, len(records))
+	for i, record := range records {
+		day, err := parseDate(string(record.Date), loc)
+		if err != nil {
+			return 0, "", err
+		}
+		days[i] = math.Round(day.Sub(start).Hours() / 24)
+		minutes[i] = record.CheckIn.Sub(day).Minutes()
+	}
+	perWeek, fit := checkInTrend(days, minutes)
+	if perWeek < float64(alertRules.DriftMinutesPerWeek) || fit < driftMinFit {
+		return 0, "", nil
+	}
+	return len(records), fmt.Sprintf("Check-in times drifted later by about %.0f minutes a week between %s and %s",
+		perWeek, start.Format(dateLayout), end.Format(dateLayout)), nil
+}
+
+// checkInTrend fits a line through check-ins, given as days since the start
+// of the window and minutes past local midnight, by least squares. It
+// returns the slope in minutes a week and how well the line fits as R², or
+// zeros when either variable does not vary.
+func checkInTrend(days, minutes []float64) (perWeek, fit float64) {
+	n := float64(len(days))
+	var sumX, sumY, sumXX, sumXY, sumYY float64
+	for i, x := range days {
+		y := minutes[i]
+		sumX += x
+		sumY += y
+		sumXX += x * x
+		sumXY += x * y
+		sumYY += y * y
+	}
+	varX, varY := n*sumXX-sumX*sumX, n*sumYY-sumY*sumY
+	if varX == 0 || varY == 0 {
+		return 0, 0
+	}
+	covariance := n*sumXY - sumX*sumY
+	return covariance / varX * 7, covariance * covariance / (varX * varY)
+}
+
+// evaluateAlertRules runs every enabled rule for every user over the window
+// ending on the user's last complete day, and returns the number of alerts
+// raised. Days an earlier alert of the same rule covered are left out, so
+// an alert is only raised again for new occurrences.
+func evaluateAlertRules(now time.Time) (int, error) {
+	rules := enabledAlertRules()
+	if len(rules) == 0 {
+		return 0, nil
+	}
+
+	var users []User
+	if err := db.Find(&users).Error; err != nil {
+		return 0, err
+	}
+
+	created := 0
+	for _, user := range users {
+		loc := userLocation(user)
+		local := now.In(loc)
+		end := time.Date(local.Year(), local.Month(), local.Day()-1, 0, 0, 0, 0, loc)
+
+		for _, rule := range rules {
+			start := end.AddDate(0, 0, 1-rule.windowDays)
+
+			var last Alert
+			if err := db.Where("user_id = ? AND rule = ?", user.ID, rule.name).
+				Order("window_end DESC").
+				Limit(1).
+				Find(&last).Error; err != nil {
+				return created, err
+			}
+			if last.ID != 0 {
+				if covered, err := parseDate(string(last.WindowEnd), loc); err == nil && !covered.Before(start) {
+					start = covered.AddDate(0, 0, 1)
+				}
+			}
+			if start.After(end) {
+				continue
+			}
+
+			occurrences, message, err := rule.check(user, start, end)
+			if err != nil {
+				return created, err
+			}
+			if occurrences == 0 {
+				continue
+			}
+
+			alert := Alert{
+				UserID:      user.ID,
+				Rule:        rule.name,
+				Fingerprint: fmt.Sprintf("%s:%d:%s", rule.name, user.ID, end.Format(dateLayout)),
+				Message:     message,
+				WindowStart: Date(start.Format(dateLayout)),
+				WindowEnd:   Date(end.Format(dateLayout)),
+				Occurrences: occurrences,
+				Status:      "open",
+			}
+			// Another run may have raised the same alert meanwhile
+			result := db.Omit(clause.Associations).Clauses(clause.OnConflict{DoNothing: true}).Create(&alert)
+			if result.Error != nil {
+				return created, result.Error
+			}
+			created += int(result.RowsAffected)
+		}
+	}
+	return created, nil
+}
+
+// checkAlertRules is the scheduled run of the alert rules.
+func checkAlertRules(now time.Time) {
+	created, err := evaluateAlertRules(now)
+	if err != nil {
+		log.Printf("attendance-alerts: %v", err)
+	}
+	if created > 0 {
+		log.Printf("attendance-alerts: raised %d alerts", created)
+	}
+}
+
+func getAlerts(c *gin.Context) {
+	page, limit := parsePagination(c)
+	offset := (page - 1) * limit
+
+	query := db.Model(&Alert{})
+
+	// Apply filters
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if rule := c.Query("rule"); rule != "" {
+		query = query.Where("rule = ?", rule)
+	}
+	if userIDParam := c.Query("user_id"); userIDParam != "" {
+		if userID, err := strconv.ParseUint(userIDParam, 10, 32); err == nil {
+			query = query.Where("user_id = ?", userID)
+		}
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var alerts []Alert
+	if err := query.Preload("User").
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&alerts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch alerts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, paginated(alerts, page, limit, total))
+}
+
+func acknowledgeAlert(c *gin.Context) {
+	reviewerID, _ := c.Get("user_id")
+
+	var req ReviewRequest
+	if err := bindOptionalJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var alert Alert
+	if err := db.Preload("User").First(&alert, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alert not found"})
+		return
+	}
+
+	if alert.Status != "open" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Alert is already acknowledged"})
+		return
+	}
+
+	now := time.Now()
+	reviewer := reviewerID.(uint)
+	alert.Status = "acknowledged"
+	alert.AcknowledgedBy = &reviewer
+	alert.AcknowledgedAt = &now
+	alert.AcknowledgeNote = req.Note
+
+	if err := db.Model(&alert).
+		Select("status", "acknowledged_by", "acknowledged_at", "acknowledge_note").
+		Updates(&alert).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to acknowledge alert"})
+		return
+	}
+
+	c.JSON(http.StatusOK, alert)
+}
+
+// runAlerts evaluates the alert rules immediately rather than waiting for
+// the scheduled run.
+func runAlerts(c *gin.Context) {
+	created, err := evaluateAlertRules(time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to evaluate alert rules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"created": created})
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCheckInTrend(t *testing.T) {
+	tests := []struct {
+		name        string
+		days        []float64
+		minutes     []float64
+		wantPerWeek float64
+		wantFit     float64
+	}{
+		{
+			name:        "steadily later",
+			days:        []float64{0, 1, 2, 3, 4, 5, 6},
+			minutes:     []float64{540, 542, 544, 546, 548, 550, 552},
+			wantPerWeek: 14,
+			wantFit:     1,
+		},
+		{
+			name:        "steadily earlier",
+			days:        []float64{0, 1, 2, 3, 4, 5, 6},
+			minutes:     []float64{540, 539, 538, 537, 536, 535, 534},
+			wantPerWeek: -7,
+			wantFit:     1,
+		},
+		{
+			name:        "workdays only",
+			days:        []float64{0, 1, 2, 3, 4, 7, 8, 9, 10, 11},
+			minutes:     []float64{540, 541, 542, 543, 544, 547, 548, 549, 550, 551},
+			wantPerWeek: 7,
+			wantFit:     1,
+		},
+		{
+			name:        "alternating",
+			days:        []float64{0, 1, 2, 3},
+			minutes:     []float64{540, 560, 540, 560},
+			wantPerWeek: 28,
+			wantFit:     0.2,
+		},
+		{
+			name:    "same time every day",
+			days:    []float64{0, 1, 2, 3},
+			minutes: []float64{540, 540, 540, 540},
+		},
+		{
+			name:    "all on one day",
+			days:    []float64{5, 5, 5},
+			minutes: []float64{540, 560, 580},
+		},
+		{
+			name: "no check-ins",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			perWeek, fit := checkInTrend(tt.days, tt.minutes)
+			if math.Abs(perWeek-tt.wantPerWeek) > 1e-9 {
+				t.Errorf("per week = %g, want %g", perWeek, tt.wantPerWeek)
+			}
+			if math.Abs(fit-tt.wantFit) > 1e-9 {
+				t.Errorf("fit = %g, want %g", fit, tt.wantFit)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// analyticsPeriods map the group_by parameter to the SQL expression giving
+// the first day of a record's period. Weeks start on Monday.
+var analyticsPeriods = map[string]string{
+	"day":   "date(attendances.date)",
+	"week":  "date(attendances.date, 'weekday 0', '-6 days')",
+	"month": "date(attendances.date, 'start of month')",
+}
+
+// analyticsGroup is one row of the aggregation query. Rows are split by
+// timezone so that check-in times can be averaged in local time.
+type analyticsGroup struct {
+	Period     string
+	Department string
+	Timezone   string
+	Employees  int
+	Records    int
+	Attended   int
+	Late       int
+	HalfDay    int
+	Absent     int
+	// Average minutes between UTC midnight of the attendance date and the
+	// check-in, NULL without check-ins
+	CheckInMinutes *float64
+}
+
+// getAnalytics aggregates attendance between start_date and end_date,
+// grouped by period and, with byDepartment, by department. Rates are
+// percentages of the recorded days, which include the absences recorded
+// by the mark-absences job.
+func getAnalytics(c *gin.Context, byDepartment bool) {
+	groupBy := c.DefaultQuery("group_by", "day")
+	period, ok := analyticsPeriods[groupBy]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid group_by, expected day, week or month"})
+		return
+	}
+
+	// Default to the current month
+	now := time.Now().In(defaultLocation)
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, defaultLocation)
+	start, end, err := parseDateRange(c, defaultLocation, start, start.AddDate(0, 1, -1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	department := "''"
+	if byDepartment {
+		department = "users.department"
+	}
+	query := db.Model(&Attendance{}).
+		Select(period+" AS period, "+department+" AS department, users.timezone AS timezone, "+
+			"COUNT(DISTINCT attendances.user_id) AS employees, "+
+			"COUNT(*) AS records, "+
+			"SUM(CASE WHEN attendances.check_in IS NOT NULL THEN 1 ELSE 0 END) AS attended, "+
+			"SUM(CASE WHEN attendances.status = 'late' THEN 1 ELSE 0 END) AS late, "+
+			"SUM(CASE WHEN attendances.status = 'half_day' THEN 1 ELSE 0 END) AS half_day, "+
+			"SUM(CASE WHEN attendances.status = 'absent' THEN 1 ELSE 0 END) AS absent, "+
+			"AVG((julianday(attendances.check_in) - julianday(attendances.date)) * 1440) AS check_in_minutes").
+		Joins("JOIN users ON users.id = attendances.user_id").
+		Where("attendances.date BETWEEN ? AND ?", start.Format(dateLayout), end.Format(dateLayout)).
+		Group("period, " + department + ", users.timezone").
+		Order("period, department")
+	if filter := c.Query("department"); filter != "" {
+		query = query.Where("users.department = ?", filter)
+	}
+
+	var groups []analyticsGroup
+	if err := query.Scan(&groups).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate analytics"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"start_date": start.Format(dateLayout),
+		"end_date":   end.Format(dateLayout),
+		"group_by":   groupBy,
+		"data":       mergeAnalyticsGroups(groups),
+	})
+}
+
+// mergeAnalyticsGroups combines the per-timezone rows of each period and
+// department, converting check-in times to local time on the way.
+func mergeAnalyticsGroups(groups []analyticsGroup) []AnalyticsRow {
+	rows := []AnalyticsRow{}
+	checkIns := map[int]struct {
+		minutes float64
+		count   int
+	}{}
+	locations := map[string]*time.Location{}
+
+	for _, group := range groups {
+		last := len(rows) - 1
+		if last < 0 || rows[last].Period != group.Period || rows[last].Department != group.Department {
+			rows = append(rows, AnalyticsRow{Period: group.Period, Department: group.Department})
+			last++
+		}
+		row := &rows[last]
+		row.Employees += group.Employees
+		row.Records += group.Records
+		row.AttendedDays += group.Attended
+		row.LateDays += group.Late
+		row.HalfDayDays += group.HalfDay
+		row.AbsentDays += group.Absent
+
+		if group.CheckInMinutes != nil && group.Attended > 0 {
+			loc, ok := locations[group.Timezone]
+			if !ok {
+				loc = userLocation(User{Timezone: group.Timezone})
+				locations[group.Timezone] = loc
+			}
+			// The offset at the start of the period; a DST change within it
+			// shifts the later days by an hour
+			day, _ := parseDate(group.Period, loc)
+			_, offset := day.Zone()
+
+			total := checkIns[last]
+			total.minutes += (*group.CheckInMinutes + float64(offset/60)) * float64(group.Attended)
+			total.count += group.Attended
+			checkIns[last] = total
+		}
+	}
+
+	for i := range rows {
+		row := &rows[i]
+		if row.Records > 0 {
+			row.AttendanceRate = percentage(row.AttendedDays, row.Records)
+		}
+		if row.AttendedDays > 0 {
+			row.LateRate = percentage(row.LateDays, row.AttendedDays)
+		}
+		if total, ok := checkIns[i]; ok {
+			average := formatClockTime(int(math.Round(total.minutes / float64(total.count))))
+			row.AverageCheckIn = &average
+		}
+	}
+	return rows
+}
+
+func percentage(part, whole int) float64 {
+	return math.Round(float64(part)/float64(whole)*10000) / 100
+}
+
+func getCompanyAnalytics(c *gin.Context) {
+	getAnalytics(c, false)
+}
+
+func getDepartmentAnalytics(c *gin.Context) {
+	getAnalytics(c, true)
+}
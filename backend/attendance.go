@@ -1,11 +1,13 @@
 package main
 
 import (
+	"math"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 func checkIn(c *gin.Context) {
@@ -17,60 +19,67 @@ func checkIn(c *gin.Context) {
 		return
 	}
 
-	today := time.Now().Truncate(24 * time.Hour)
-	
-	// Check if user already checked in today
-	var existingAttendance Attendance
-	if err := db.Where("user_id = ? AND date = ?", userID, today).First(&existingAttendance).Error; err == nil {
-		if existingAttendance.CheckIn != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Already checked in today"})
-			return
-		}
+	var user User
+	if err := db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
 	}
 
-	now := time.Now()
+	// "Today" is the date the current shift started on, in the user's timezone
+	now := time.Now().In(userLocation(user))
+	today := scheduleFor(user).shiftDate(now)
 	
-	// Determine status based on check-in time (assuming work starts at 9:00 AM)
-	workStartTime := time.Date(now.Year(), now.Month(), now.Day(), 9, 0, 0, 0, now.Location())
-	status := "present"
-	if now.After(workStartTime.Add(15 * time.Minute)) { // 15 minutes grace period
-		status = "late"
-	}
-
-	if existingAttendance.ID != 0 {
-		// Update existing record
-		existingAttendance.CheckIn = &now
-		existingAttendance.Status = status
-		existingAttendance.Notes = req.Notes
-		
-		if err := db.Save(&existingAttendance).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update check-in"})
-			return
-		}
-		
-		c.JSON(http.StatusOK, existingAttendance)
-	} else {
-		// Create new attendance record
-		attendance := Attendance{
-			UserID:  userID.(uint),
-			Date:    today,
-			CheckIn: &now,
-			Status:  status,
-			Notes:   req.Notes,
-		}
+	// Check if user is already checked in, possibly on a shift that started
+	// on an earlier day
+	if _, err := openAttendance(user.ID); err == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Already checked in"})
+		return
+	}
+
+	if isPeriodLocked(user.ID, today, today) {
+		c.JSON(http.StatusConflict, gin.H{"error": errPeriodLocked.Error()})
+		return
+	}
 
-		if err := db.Create(&attendance).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record check-in"})
-			return
+	// Checking in again after a check-out starts a new session on the same shift
+	var attendance Attendance
+	db.Where("user_id = ? AND date = ?", userID, today).First(&attendance)
+
+	created := attendance.ID == 0
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if created {
+			attendance = Attendance{
+				UserID: user.ID,
+				Date:   Date(today),
+				Notes:  req.Notes,
+			}
+			if err := tx.Create(&attendance).Error; err != nil {
+				return err
+			}
+		} else {
+			attendance.Notes = appendNote(attendance.Notes, req.Notes)
 		}
 
-		// Load user data
-		if err := db.Preload("User").First(&attendance, attendance.ID).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load attendance data"})
-			return
+		if err := startSession(tx, attendance.ID, sessionWork, now); err != nil {
+			return err
 		}
+		return recomputeAttendance(tx, &attendance, user)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record check-in"})
+		return
+	}
 
+	// Load user and session data
+	if err := loadAttendance(&attendance); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load attendance data"})
+		return
+	}
+
+	if created {
 		c.JSON(http.StatusCreated, attendance)
+	} else {
+		c.JSON(http.StatusOK, attendance)
 	}
 }
 
@@ -83,12 +92,18 @@ func checkOut(c *gin.Context) {
 		return
 	}
 
-	today := time.Now().Truncate(24 * time.Hour)
+	var user User
+	if err := db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	now := time.Now().In(userLocation(user))
 	
-	// Find today's attendance record
-	var attendance Attendance
-	if err := db.Where("user_id = ? AND date = ?", userID, today).First(&attendance).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "No check-in found for today"})
+	// Find the open shift, whichever day it started on
+	attendance, sessions, err := currentAttendance(user, now)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No check-in found"})
 		return
 	}
 
@@ -97,38 +112,95 @@ func checkOut(c *gin.Context) {
 		return
 	}
 
-	if attendance.CheckOut != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Already checked out today"})
+	// Checking out also ends a running break
+	open := openSession(sessions)
+	if open == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Already checked out"})
 		return
 	}
 
-	now := time.Now()
-	attendance.CheckOut = &now
+	if isPeriodLocked(user.ID, string(attendance.Date), string(attendance.Date)) {
+		c.JSON(http.StatusConflict, gin.H{"error": errPeriodLocked.Error()})
+		return
+	}
 	
 	// Update notes if provided
 	if req.Notes != "" {
-		if attendance.Notes != "" {
-			attendance.Notes += " | Checkout: " + req.Notes
-		} else {
-			attendance.Notes = "Checkout: " + req.Notes
+		attendance.Notes = appendNote(attendance.Notes, "Checkout: "+req.Notes)
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := endSession(tx, open, now); err != nil {
+			return err
 		}
+		return recomputeAttendance(tx, &attendance, user)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record check-out"})
+		return
 	}
 
-	// Calculate work duration
-	workDuration := now.Sub(*attendance.CheckIn)
-	
-	// Determine if it's half day (less than 4 hours)
-	if workDuration.Hours() < 4 && attendance.Status != "late" {
-		attendance.Status = "half_day"
+	// Load user and session data
+	if err := loadAttendance(&attendance); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load attendance data"})
+		return
 	}
 
-	if err := db.Save(&attendance).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record check-out"})
+	c.JSON(http.StatusOK, attendance)
+}
+
+func startBreak(c *gin.Context) {
+	switchSession(c, sessionWork, sessionBreak, "Must be checked in and not on a break", "Failed to start break")
+}
+
+func endBreak(c *gin.Context) {
+	switchSession(c, sessionBreak, sessionWork, "No break in progress", "Failed to end break")
+}
+
+// switchSession ends the running session of type from and starts one of type to.
+func switchSession(c *gin.Context, from, to, invalidMessage, failMessage string) {
+	userID, _ := c.Get("user_id")
+
+	var user User
+	if err := db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
 
-	// Load user data
-	if err := db.Preload("User").First(&attendance, attendance.ID).Error; err != nil {
+	now := time.Now().In(userLocation(user))
+
+	attendance, sessions, err := currentAttendance(user, now)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No check-in found"})
+		return
+	}
+
+	open := openSession(sessions)
+	if open == nil || open.Type != from {
+		c.JSON(http.StatusBadRequest, gin.H{"error": invalidMessage})
+		return
+	}
+
+	if isPeriodLocked(user.ID, string(attendance.Date), string(attendance.Date)) {
+		c.JSON(http.StatusConflict, gin.H{"error": errPeriodLocked.Error()})
+		return
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := endSession(tx, open, now); err != nil {
+			return err
+		}
+		if err := startSession(tx, attendance.ID, to, now); err != nil {
+			return err
+		}
+		return recomputeAttendance(tx, &attendance, user)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": failMessage})
+		return
+	}
+
+	if err := loadAttendance(&attendance); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load attendance data"})
 		return
 	}
@@ -136,12 +208,55 @@ func checkOut(c *gin.Context) {
 	c.JSON(http.StatusOK, attendance)
 }
 
+// currentAttendance finds the user's open shift, or failing that the record
+// of the current shift, along with its sessions.
+func currentAttendance(user User, now time.Time) (Attendance, []AttendanceSession, error) {
+	attendance, err := openAttendance(user.ID)
+	if err != nil {
+		err = db.Where("user_id = ? AND date = ?", user.ID, scheduleFor(user).shiftDate(now)).First(&attendance).Error
+	}
+	if err != nil {
+		return attendance, nil, err
+	}
+
+	sessions, err := loadSessions(db, attendance.ID)
+	return attendance, sessions, err
+}
+
+// openAttendance finds the user's attendance record that is checked in but
+// not yet checked out. Overnight shifts stay open past midnight.
+func openAttendance(userID uint) (Attendance, error) {
+	var attendance Attendance
+	err := db.Where("user_id = ? AND check_in IS NOT NULL AND check_out IS NULL", userID).
+		Order("date DESC").
+		First(&attendance).Error
+	return attendance, err
+}
+
+// loadAttendance reloads an attendance record with its user and sessions.
+func loadAttendance(attendance *Attendance) error {
+	return db.Preload("User").
+		Preload("Sessions", func(tx *gorm.DB) *gorm.DB { return tx.Order("started_at, id") }).
+		First(attendance, attendance.ID).Error
+}
+
 func getTodayAttendance(c *gin.Context) {
 	userID, _ := c.Get("user_id")
-	today := time.Now().Truncate(24 * time.Hour)
 
-	var attendance Attendance
-	err := db.Preload("User").Where("user_id = ? AND date = ?", userID, today).First(&attendance).Error
+	var user User
+	if err := db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	now := time.Now().In(userLocation(user))
+	today := scheduleFor(user).shiftDate(now)
+
+	// An open shift from a previous day is still the current one
+	attendance, _, err := currentAttendance(user, now)
+	if err == nil {
+		err = loadAttendance(&attendance)
+	}
 	
 	if err != nil {
 		// No attendance record for today
@@ -208,66 +323,179 @@ func getAttendanceHistory(c *gin.Context) {
 
 func getAttendanceStats(c *gin.Context) {
 	userID, _ := c.Get("user_id")
-	
+
+	var user User
+	if err := db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	writeAttendanceStats(c, user)
+}
+
+func getUserAttendanceStats(c *gin.Context) {
+	var user User
+	if err := db.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	writeAttendanceStats(c, user)
+}
+
+// writeAttendanceStats responds with user's stats for the start_date and
+// end_date query parameters, the current month by default.
+func writeAttendanceStats(c *gin.Context, user User) {
 	// Get query parameters for date range (default to current month)
-	now := time.Now()
-	startDate := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	loc := userLocation(user)
+	now := time.Now().In(loc)
+	startDate := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
 	endDate := startDate.AddDate(0, 1, -1) // Last day of current month
 
-	if start := c.Query("start_date"); start != "" {
-		if parsed, err := time.Parse("2006-01-02", start); err == nil {
-			startDate = parsed
-		}
+	startDate, endDate, err := parseDateRange(c, loc, startDate, endDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
-	
-	if end := c.Query("end_date"); end != "" {
-		if parsed, err := time.Parse("2006-01-02", end); err == nil {
-			endDate = parsed
-		}
+
+	stats, err := computeAttendanceStats(user, startDate, endDate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate attendance stats"})
+		return
 	}
 
+	c.JSON(http.StatusOK, stats)
+}
+
+// computeAttendanceStats summarizes user's attendance between startDate and
+// endDate, both inclusive. Days after today are neither absent nor counted
+// towards the attendance rate.
+func computeAttendanceStats(user User, startDate, endDate time.Time) (AttendanceStats, error) {
+	startKey, endKey := startDate.Format(dateLayout), endDate.Format(dateLayout)
+
 	var stats AttendanceStats
 
-	// Count total working days in the period (excluding weekends)
-	totalDays := 0
-	for d := startDate; d.Before(endDate.AddDate(0, 0, 1)); d = d.AddDate(0, 0, 1) {
-		if d.Weekday() != time.Saturday && d.Weekday() != time.Sunday {
-			totalDays++
+	loc := userLocation(user)
+	schedule := scheduleFor(user)
+
+	// One pass over the period's records gives the day counts, worked time,
+	// approved overtime and the times of day of check-ins
+	var records []Attendance
+	if err := db.Select("date", "status", "check_in", "check_out", "worked_minutes", "overtime_minutes", "overtime_status").
+		Where("user_id = ? AND date BETWEEN ? AND ?", user.ID, startKey, endKey).
+		Find(&records).Error; err != nil {
+		return stats, err
+	}
+	workedMinutes, overtimeMinutes := 0, 0
+	attended := make(map[string]Attendance, len(records))
+	var checkInMinutes, checkOutMinutes []float64
+	for _, record := range records {
+		switch record.Status {
+		case "present":
+			stats.PresentDays++
+		case "late":
+			stats.LateDays++
+		case "half_day":
+			stats.HalfDayDays++
+		}
+		workedMinutes += record.WorkedMinutes
+		if record.OvertimeStatus == "approved" {
+			overtimeMinutes += record.OvertimeMinutes
+		}
+
+		// Days with a check-in, and their average times of day
+		if record.CheckIn == nil {
+			continue
+		}
+		key := string(record.Date)
+		attended[key] = record
+
+		day, err := parseDate(key, loc)
+		if err != nil {
+			continue
+		}
+		checkInMinutes = append(checkInMinutes, record.CheckIn.Sub(day).Minutes())
+		if record.CheckOut != nil {
+			checkOutMinutes = append(checkOutMinutes, record.CheckOut.Sub(day).Minutes())
 		}
 	}
+	stats.AverageCheckIn = averageClockTime(checkInMinutes)
+	stats.AverageCheckOut = averageClockTime(checkOutMinutes)
 
-	// Count present days
-	var presentCount int64
-	db.Model(&Attendance{}).
-		Where("user_id = ? AND date BETWEEN ? AND ? AND status = ?", userID, startDate, endDate, "present").
-		Count(&presentCount)
-
-	// Count late days
-	var lateCount int64
-	db.Model(&Attendance{}).
-		Where("user_id = ? AND date BETWEEN ? AND ? AND status = ?", userID, startDate, endDate, "late").
-		Count(&lateCount)
-
-	// Count half days
-	var halfDayCount int64
-	db.Model(&Attendance{}).
-		Where("user_id = ? AND date BETWEEN ? AND ? AND status = ?", userID, startDate, endDate, "half_day").
-		Count(&halfDayCount)
-
-	// Count total attendance records
-	var totalAttendance int64
-	db.Model(&Attendance{}).
-		Where("user_id = ? AND date BETWEEN ? AND ? AND check_in IS NOT NULL", userID, startDate, endDate).
-		Count(&totalAttendance)
-
-	stats.TotalDays = totalDays
-	stats.PresentDays = int(presentCount)
-	stats.LateDays = int(lateCount)
-	stats.AbsentDays = totalDays - int(totalAttendance)
-	
-	if totalDays > 0 {
-		stats.AttendanceRate = float64(totalAttendance) / float64(totalDays) * 100
+	// Approved leave counts as its own category rather than as absence
+	leave, err := approvedLeaveByDate(db, user, startDate, endDate)
+	if err != nil {
+		return stats, err
 	}
 
-	c.JSON(http.StatusOK, stats)
-}
\ No newline at end of file
+	// Holidays are not working days
+	holidays, err := holidaysFor(db, user, startKey, endKey)
+	if err != nil {
+		return stats, err
+	}
+
+	// Walk the working days of the period according to the user's schedule.
+	// A punctuality streak is a run of working days attended on time; days
+	// off, holidays and leave neither extend nor break it.
+	today := time.Now().In(loc).Format(dateLayout)
+	streak := 0
+	for d := startDate; !d.After(endDate); d = d.AddDate(0, 0, 1) {
+		key := d.Format(dateLayout)
+		record, wasAttended := attended[key]
+		if _, holiday := holidays[key]; holiday {
+			if schedule.isWorkingDay(d) {
+				stats.HolidayDays++
+			}
+			if wasAttended {
+				stats.HolidayWorkDays++
+			}
+			continue
+		}
+		if !schedule.isWorkingDay(d) {
+			continue
+		}
+
+		stats.TotalDays++
+		switch {
+		case wasAttended:
+			stats.AttendedDays++
+			if record.Status == "present" {
+				streak++
+				stats.LongestOnTimeStreak = max(stats.LongestOnTimeStreak, streak)
+			} else {
+				streak = 0
+			}
+		case leave[key] > 0:
+			stats.LeaveDays += leave[key]
+		case key < today:
+			stats.AbsentDays++
+			streak = 0
+		}
+	}
+	stats.CurrentOnTimeStreak = streak
+
+	stats.TotalWorkedHours = minutesToHours(workedMinutes)
+	stats.OvertimeHours = minutesToHours(overtimeMinutes)
+
+	// Only days that have passed are expected attendance, and days on
+	// leave are not expected at all
+	if expected := stats.AttendedDays + stats.AbsentDays; expected > 0 {
+		stats.AttendanceRate = float64(stats.AttendedDays) / float64(expected) * 100
+	}
+
+	return stats, nil
+}
+
+// averageClockTime returns the mean of minutes past local midnight as HH:MM,
+// or nil without any. Times after midnight of the next day wrap around.
+func averageClockTime(minutes []float64) *string {
+	if len(minutes) == 0 {
+		return nil
+	}
+	total := 0.0
+	for _, m := range minutes {
+		total += m
+	}
+	clock := formatClockTime(int(math.Round(total / float64(len(minutes)))))
+	return &clock
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// validateAttendanceTimes checks the times of user's attendance on date: the
+// check-in must belong to that day's shift, the check-out must follow it and
+// neither may be in the future.
+func validateAttendanceTimes(user User, date string, checkIn, checkOut *time.Time) error {
+	if checkIn == nil {
+		if checkOut != nil {
+			return errors.New("A check-out requires a check-in")
+		}
+		return nil
+	}
+
+	now := time.Now()
+	if checkIn.After(now) || (checkOut != nil && checkOut.After(now)) {
+		return errors.New("Attendance times cannot be in the future")
+	}
+	if checkOut != nil && !checkOut.After(*checkIn) {
+		return errors.New("Check-out must be after check-in")
+	}
+	if scheduleFor(user).shiftDate(checkIn.In(userLocation(user))) != date {
+		return errors.New("Check-in must fall on the attendance date")
+	}
+	return nil
+}
+
+// checkAttendanceConflicts reports an error if user has another record on
+// date, or one whose shift overlaps the given times. A shift without a
+// check-out is treated as still running.
+func checkAttendanceConflicts(user User, date string, checkIn, checkOut *time.Time, excludeID uint) error {
+	var count int64
+	if err := db.Model(&Attendance{}).
+		Where("user_id = ? AND date = ? AND id <> ?", user.ID, date, excludeID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("Attendance already exists for this date")
+	}
+	if checkIn == nil {
+		return nil
+	}
+
+	// Only shifts starting on neighbouring days can overlap
+	day, err := parseDate(date, time.UTC)
+	if err != nil {
+		return err
+	}
+	var neighbours []Attendance
+	if err := db.Where("user_id = ? AND id <> ? AND check_in IS NOT NULL AND date BETWEEN ? AND ?",
+		user.ID, excludeID, day.AddDate(0, 0, -1).Format(dateLayout), day.AddDate(0, 0, 1).Format(dateLayout)).
+		Find(&neighbours).Error; err != nil {
+		return err
+	}
+
+	for _, other := range neighbours {
+		startsBeforeEnd := checkOut == nil || other.CheckIn.Before(*checkOut)
+		endsAfterStart := other.CheckOut == nil || other.CheckOut.After(*checkIn)
+		if startsBeforeEnd && endsAfterStart {
+			return errors.New("Attendance overlaps the shift on " + string(other.Date))
+		}
+	}
+	return nil
+}
+
+// logAttendanceChange adds an entry to the audit log of an attendance record.
+func logAttendanceChange(tx *gorm.DB, attendanceID uint, actorID *uint, action, reason string, before, after *AttendanceSnapshot) error {
+	return tx.Create(&AttendanceAuditLog{
+		AttendanceID: attendanceID,
+		ActorID:      actorID,
+		Action:       action,
+		Reason:       reason,
+		Before:       before,
+		After:        after,
+	}).Error
+}
+
+func createAttendance(c *gin.Context) {
+	actorID, _ := c.Get("user_id")
+	actor := actorID.(uint)
+
+	var req CreateAttendanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user User
+	if err := db.First(&user, req.UserID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if _, err := parseDate(req.Date, userLocation(user)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date, expected YYYY-MM-DD"})
+		return
+	}
+	if err := validateAttendanceTimes(user, req.Date, req.CheckIn, req.CheckOut); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := checkAttendanceConflicts(user, req.Date, req.CheckIn, req.CheckOut, 0); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if isPeriodLocked(user.ID, req.Date, req.Date) {
+		c.JSON(http.StatusConflict, gin.H{"error": errPeriodLocked.Error()})
+		return
+	}
+
+	attendance := Attendance{
+		UserID: user.ID,
+		Date:   Date(req.Date),
+		Notes:  req.Notes,
+		Status: "absent",
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&attendance).Error; err != nil {
+			return err
+		}
+		if err := retimeSessions(tx, attendance.ID, req.CheckIn, req.CheckOut); err != nil {
+			return err
+		}
+		if err := recomputeAttendance(tx, &attendance, user); err != nil {
+			return err
+		}
+		return logAttendanceChange(tx, attendance.ID, &actor, "create", req.Reason, nil, snapshotAttendance(attendance))
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create attendance record"})
+		return
+	}
+
+	if err := loadAttendance(&attendance); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load attendance data"})
+		return
+	}
+	c.JSON(http.StatusCreated, attendance)
+}
+
+// updateAttendance edits an attendance record. Fields left out keep their
+// current values; the status is always re-derived from the work schedule.
+func updateAttendance(c *gin.Context) {
+	actorID, _ := c.Get("user_id")
+	actor := actorID.(uint)
+
+	var attendance Attendance
+	if err := db.Preload("User").First(&attendance, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attendance record not found"})
+		return
+	}
+	user := attendance.User
+
+	var req UpdateAttendanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if isPeriodLocked(user.ID, string(attendance.Date), string(attendance.Date)) {
+		c.JSON(http.StatusConflict, gin.H{"error": errPeriodLocked.Error()})
+		return
+	}
+
+	date := string(attendance.Date)
+	if req.Date != "" {
+		if _, err := parseDate(req.Date, userLocation(user)); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date, expected YYYY-MM-DD"})
+			return
+		}
+		if isPeriodLocked(user.ID, req.Date, req.Date) {
+			c.JSON(http.StatusConflict, gin.H{"error": errPeriodLocked.Error()})
+			return
+		}
+		date = req.Date
+	}
+
+	if req.Date != "" || req.CheckIn != nil || req.CheckOut != nil {
+		checkIn, checkOut := attendance.CheckIn, attendance.CheckOut
+		if req.CheckIn != nil {
+			checkIn = req.CheckIn
+		}
+		if req.CheckOut != nil {
+			checkOut = req.CheckOut
+		}
+
+		if err := validateAttendanceTimes(user, date, checkIn, checkOut); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := checkAttendanceConflicts(user, date, checkIn, checkOut, attendance.ID); err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	before := snapshotAttendance(attendance)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := retimeSessions(tx, attendance.ID, req.CheckIn, req.CheckOut); err != nil {
+			return err
+		}
+
+		attendance.Date = Date(date)
+		if req.Notes != nil {
+			attendance.Notes = *req.Notes
+		}
+		if req.CheckIn != nil || req.CheckOut != nil {
+			attendance.NeedsReview = false
+		}
+		if req.CheckOut != nil {
+			attendance.AutoClosed = false
+		}
+		if err := recomputeAttendance(tx, &attendance, user); err != nil {
+			return err
+		}
+		// Moving a record changes the overtime of the week it left
+		if before.Date != attendance.Date {
+			if err := recomputeOvertime(tx, user, string(before.Date), nil); err != nil {
+				return err
+			}
+		}
+		return logAttendanceChange(tx, attendance.ID, &actor, "update", req.Reason, before, snapshotAttendance(attendance))
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update attendance record"})
+		return
+	}
+
+	if err := loadAttendance(&attendance); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load attendance data"})
+		return
+	}
+	c.JSON(http.StatusOK, attendance)
+}
+
+func deleteAttendance(c *gin.Context) {
+	actorID, _ := c.Get("user_id")
+	actor := actorID.(uint)
+
+	var attendance Attendance
+	if err := db.Preload("User").First(&attendance, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attendance record not found"})
+		return
+	}
+
+	if isPeriodLocked(attendance.UserID, string(attendance.Date), string(attendance.Date)) {
+		c.JSON(http.StatusConflict, gin.H{"error": errPeriodLocked.Error()})
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&attendance).Error; err != nil {
+			return err
+		}
+		if err := recomputeOvertime(tx, attendance.User, string(attendance.Date), nil); err != nil {
+			return err
+		}
+		return logAttendanceChange(tx, attendance.ID, &actor, "delete", c.Query("reason"), snapshotAttendance(attendance), nil)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete attendance record"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Attendance record deleted successfully"})
+}
+
+// getAttendanceAudit lists the changes made to an attendance record,
+// including one that has since been deleted.
+func getAttendanceAudit(c *gin.Context) {
+	var entries []AttendanceAuditLog
+	if err := db.Preload("Actor").
+		Where("attendance_id = ?", c.Param("id")).
+		Order("created_at, id").
+		Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch audit log"})
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
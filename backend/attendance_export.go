@@ -0,0 +1,166 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// attendanceExportFlushRows is how many rows are buffered before the export
+// is flushed to the client.
+const attendanceExportFlushRows = 500
+
+var attendanceExportHeader = []string{
+	"Date", "Employee ID", "Name", "Email", "Department", "Check In", "Check Out",
+	"Worked Hours", "Break Hours", "Overtime Hours", "Status", "Notes",
+}
+
+// attendanceExportRow is one line of the export, read straight from the
+// joined attendances and users tables.
+type attendanceExportRow struct {
+	Date            Date
+	UserID          uint
+	Name            string
+	Email           string
+	Department      string
+	Timezone        string
+	CheckIn         sql.NullTime
+	CheckOut        sql.NullTime
+	WorkedMinutes   int
+	BreakMinutes    int
+	OvertimeMinutes int
+	Status          string
+	Notes           string
+}
+
+// exportAttendance streams the attendance records matching the filters of
+// getAllAttendance as CSV or XLSX. Rows are written as they are read, so
+// the export size is not bounded by memory.
+func exportAttendance(c *gin.Context) {
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "xlsx" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format must be csv or xlsx"})
+		return
+	}
+
+	query := filterAttendance(c, db.Table("attendances").
+		Select("attendances.date, attendances.user_id, users.name, users.email, users.department, users.timezone, "+
+			"attendances.check_in, attendances.check_out, attendances.worked_minutes, attendances.break_minutes, "+
+			"attendances.overtime_minutes, attendances.status, attendances.notes").
+		Joins("JOIN users ON users.id = attendances.user_id").
+		Where("attendances.deleted_at IS NULL"))
+	if department := c.Query("department"); department != "" {
+		query = query.Where("users.department = ?", department)
+	}
+
+	rows, err := query.Order("attendances.date, users.name, attendances.id").Rows()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch attendance records"})
+		return
+	}
+	defer rows.Close()
+
+	name := "attendance"
+	if startDate := c.Query("start_date"); startDate != "" {
+		name += "_" + startDate
+	}
+	if endDate := c.Query("end_date"); endDate != "" {
+		name += "_" + endDate
+	}
+	contentType := "text/csv; charset=utf-8"
+	if format == "xlsx" {
+		contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	c.Header("Content-Disposition", `attachment; filename="`+name+"."+format+`"`)
+	c.Header("Content-Type", contentType)
+	c.Status(http.StatusOK)
+
+	// Times are shown in each employee's own timezone
+	locations := map[string]*time.Location{}
+	formatTime := func(t sql.NullTime, tz string) string {
+		if !t.Valid {
+			return ""
+		}
+		loc, ok := locations[tz]
+		if !ok {
+			loc = userLocation(User{Timezone: tz})
+			locations[tz] = loc
+		}
+		return t.Time.In(loc).Format("2006-01-02 15:04")
+	}
+
+	var write func(attendanceExportRow) error
+	var flush func() error
+	if format == "xlsx" {
+		sheet, err := newXLSXWriter(c.Writer, "Attendance")
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		defer sheet.Close()
+
+		header := make([]interface{}, len(attendanceExportHeader))
+		for i, title := range attendanceExportHeader {
+			header[i] = title
+		}
+		sheet.WriteRow(header...)
+
+		write = func(row attendanceExportRow) error {
+			return sheet.WriteRow(string(row.Date), row.UserID, row.Name, row.Email, row.Department,
+				formatTime(row.CheckIn, row.Timezone), formatTime(row.CheckOut, row.Timezone),
+				minutesToHours(row.WorkedMinutes), minutesToHours(row.BreakMinutes), minutesToHours(row.OvertimeMinutes),
+				row.Status, row.Notes)
+		}
+		flush = sheet.Flush
+	} else {
+		writer := csv.NewWriter(c.Writer)
+		defer writer.Flush()
+		writer.Write(attendanceExportHeader)
+
+		write = func(row attendanceExportRow) error {
+			return writer.Write([]string{
+				string(row.Date), strconv.FormatUint(uint64(row.UserID), 10), csvText(row.Name), csvText(row.Email), csvText(row.Department),
+				formatTime(row.CheckIn, row.Timezone), formatTime(row.CheckOut, row.Timezone),
+				formatHours(row.WorkedMinutes), formatHours(row.BreakMinutes), formatHours(row.OvertimeMinutes),
+				row.Status, csvText(row.Notes),
+			})
+		}
+		flush = func() error {
+			writer.Flush()
+			return writer.Error()
+		}
+	}
+
+	for count := 1; rows.Next(); count++ {
+		var row attendanceExportRow
+		if err := rows.Scan(&row.Date, &row.UserID, &row.Name, &row.Email, &row.Department, &row.Timezone,
+			&row.CheckIn, &row.CheckOut, &row.WorkedMinutes, &row.BreakMinutes, &row.OvertimeMinutes,
+			&row.Status, &row.Notes); err != nil {
+			c.Error(err)
+			return
+		}
+		// The response has started, so a failed write can only end it early
+		if err := write(row); err != nil {
+			c.Error(err)
+			return
+		}
+		if count%attendanceExportFlushRows == 0 {
+			if err := flush(); err != nil {
+				c.Error(err)
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+	if err := rows.Err(); err != nil {
+		c.Error(err)
+	}
+}
+
+func formatHours(minutes int) string {
+	return strconv.FormatFloat(minutesToHours(minutes), 'f', 2, 64)
+}
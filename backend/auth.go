@@ -2,45 +2,53 @@ package main
 
 import (
 	"net/http"
-	"os"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
+// jwtSecret signs access tokens; loadConfig sets it from JWT_SECRET.
 var jwtSecret = []byte("your-secret-key")
 
 type Claims struct {
-	UserID uint   `json:"user_id"`
-	Email  string `json:"email"`
-	Role   string `json:"role"`
+	UserID    uint   `json:"user_id"`
+	Email     string `json:"email"`
+	Role      string `json:"role"`
+	SessionID uint   `json:"sid"`
+	Version   uint   `json:"ver"` // User.TokenVersion when issued
 	jwt.RegisteredClaims
 }
 
-func init() {
-	if secret := os.Getenv("JWT_SECRET"); secret != "" {
-		jwtSecret = []byte(secret)
-	}
-}
-
-func generateToken(user User) (string, error) {
+// generateToken issues an access token for user in the given session and
+// returns it with its expiry.
+func generateToken(user User, sessionID uint) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(accessTokenTTL)
 	claims := Claims{
-		UserID: user.ID,
-		Email:  user.Email,
-		Role:   user.Role,
+		UserID:    user.ID,
+		Email:     user.Email,
+		Role:      user.Role,
+		SessionID: sessionID,
+		Version:   user.TokenVersion,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
+	signed, err := token.SignedString(jwtSecret)
+	return signed, expiresAt, err
 }
 
+// dummyPasswordHash is compared against when logging in with an unknown
+// email. It must use the same cost as hashPassword.
+const dummyPasswordHash = "$2a$14$dg1E/Ee/ZYQTIPpKvsDPjekCsGaBxTzDs87pLn/0Z7J89xgJJINcG"
+
 func hashPassword(password string) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
 	return string(bytes), err
@@ -58,6 +66,16 @@ func register(c *gin.Context) {
 		return
 	}
 
+	if err := validateTimezone(req.Timezone); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := validatePassword(req.Password); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Check if user already exists
 	var existingUser User
 	if err := db.Where("email = ?", req.Email).First(&existingUser).Error; err == nil {
@@ -79,6 +97,7 @@ func register(c *gin.Context) {
 		Password:   hashedPassword,
 		Position:   req.Position,
 		Department: req.Department,
+		Timezone:   req.Timezone,
 		Role:       "employee",
 	}
 
@@ -87,17 +106,14 @@ func register(c *gin.Context) {
 		return
 	}
 
-	// Generate token
-	token, err := generateToken(user)
+	// Start a session
+	response, err := openUserSession(c, user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
-	c.JSON(http.StatusCreated, AuthResponse{
-		Token: token,
-		User:  user,
-	})
+	c.JSON(http.StatusCreated, response)
 }
 
 func login(c *gin.Context) {
@@ -110,6 +126,9 @@ func login(c *gin.Context) {
 	// Find user
 	var user User
 	if err := db.Where("email = ?", req.Email).First(&user).Error; err != nil {
+		// Take as long as a wrong password would, so that the response
+		// time does not reveal whether the email is registered
+		checkPassword(req.Password, dummyPasswordHash)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
@@ -120,17 +139,26 @@ func login(c *gin.Context) {
 		return
 	}
 
-	// Generate token
-	token, err := generateToken(user)
+	// With two-factor authentication the session starts once the code
+	// is checked by verifyLogin
+	if user.TwoFactorEnabled {
+		challenge, err := openMFAChallenge(user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+			return
+		}
+		c.JSON(http.StatusOK, challenge)
+		return
+	}
+
+	// Start a session
+	response, err := openUserSession(c, user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
-	c.JSON(http.StatusOK, AuthResponse{
-		Token: token,
-		User:  user,
-	})
+	c.JSON(http.StatusOK, response)
 }
 
 func authMiddleware() gin.HandlerFunc {
@@ -160,9 +188,32 @@ func authMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		c.Set("user_id", claims.UserID)
-		c.Set("user_email", claims.Email)
-		c.Set("user_role", claims.Role)
+		// Tokens of revoked sessions, and tokens issued without one, are refused
+		if !sessionActive(claims.SessionID) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has been revoked"})
+			c.Abort()
+			return
+		}
+
+		// The account may have been deleted or changed since the token was
+		// issued; the role is taken from the account, not the token
+		var user User
+		if err := db.Select("id", "email", "role", "token_version", "two_factor_enabled").First(&user, claims.UserID).Error; err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Account no longer exists"})
+			c.Abort()
+			return
+		}
+		if user.TokenVersion != claims.Version {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token is no longer valid"})
+			c.Abort()
+			return
+		}
+
+		c.Set("user_id", user.ID)
+		c.Set("user_email", user.Email)
+		c.Set("user_role", user.Role)
+		c.Set("session_id", claims.SessionID)
+		c.Set("two_factor_enabled", user.TwoFactorEnabled)
 		c.Next()
 	})
 }
@@ -175,6 +226,33 @@ func adminMiddleware() gin.HandlerFunc {
 			c.Abort()
 			return
 		}
+
+		if twoFactor, _ := c.Get("two_factor_enabled"); twoFactor != true {
+			settings, err := securitySettings()
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch security settings"})
+				c.Abort()
+				return
+			}
+			if settings.RequireAdminTwoFactor {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication is required for admins", "code": "two_factor_required"})
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	})
+}
+
+// managerMiddleware admits managers and admins.
+func managerMiddleware() gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		role, exists := c.Get("user_role")
+		if !exists || (role != "manager" && role != "admin") {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Manager access required"})
+			c.Abort()
+			return
+		}
 		c.Next()
 	})
 }
@@ -216,11 +294,95 @@ func updateProfile(c *gin.Context) {
 	if req.Department != "" {
 		user.Department = req.Department
 	}
+	if req.Timezone != "" {
+		if err := validateTimezone(req.Timezone); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		user.Timezone = req.Timezone
+	}
 
-	if err := db.Save(&user).Error; err != nil {
+	if err := db.Model(&user).
+		Select("name", "position", "department", "timezone", "updated_at").
+		Updates(&user).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
 		return
 	}
 
 	c.JSON(http.StatusOK, user)
-}
\ No newline at end of file
+}
+
+// changePassword sets a new password after checking the current one. The
+// user's other sessions are signed out; this one continues with the new
+// tokens in the response.
+func changePassword(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	sessionID, _ := c.Get("session_id")
+
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user User
+	if err := db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if !checkPassword(req.CurrentPassword, user.Password) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Current password is incorrect"})
+		return
+	}
+	if !checkNewPassword(c, user, req.NewPassword) {
+		return
+	}
+
+	hashedPassword, err := hashPassword(req.NewPassword)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	now := time.Now()
+	var session UserSession
+	var refreshToken string
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := setPassword(tx, user, hashedPassword); err != nil {
+			return err
+		}
+		if err := tx.Model(&UserSession{}).
+			Where("user_id = ? AND id <> ? AND revoked_at IS NULL", user.ID, sessionID).
+			Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+
+		// Refresh tokens handed out before the change stop working too
+		if err := tx.First(&session, sessionID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&RefreshToken{}).
+			Where("session_id = ? AND used_at IS NULL", session.ID).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+		refreshToken, err = issueRefreshToken(tx, &session, now)
+		if err != nil {
+			return err
+		}
+		return tx.First(&user, user.ID).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		return
+	}
+
+	token, expiresAt, err := generateToken(user, session.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, AuthResponse{Token: token, ExpiresAt: expiresAt, RefreshToken: refreshToken, User: user})
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Auto-checkout policies for shifts left open
+const (
+	autoCheckoutScheduledEnd = "scheduled_end" // close at the scheduled end of the shift
+	autoCheckoutMaxHours     = "max_hours"     // close a fixed number of hours after the open session started
+	autoCheckoutFlag         = "flag"          // close without crediting the open session and flag for review
+	autoCheckoutOff          = "off"
+)
+
+// autoCheckoutGraceHours is how long after the scheduled end a shift may stay
+// open before the scheduled_end and flag policies act on it.
+var autoCheckoutGraceHours = 4
+
+func validAutoCheckoutPolicy(policy string) bool {
+	switch policy {
+	case "", autoCheckoutScheduledEnd, autoCheckoutMaxHours, autoCheckoutFlag, autoCheckoutOff:
+		return true
+	}
+	return false
+}
+
+// autoCheckoutPolicy returns the schedule's auto-checkout policy and hour
+// limit, falling back to the default schedule when unset.
+func (s WorkSchedule) autoCheckoutPolicy() (string, int) {
+	policy, hours := s.AutoCheckoutPolicy, s.AutoCheckoutHours
+	if policy == "" {
+		policy = defaultSchedule.AutoCheckoutPolicy
+	}
+	if hours <= 0 {
+		hours = defaultSchedule.AutoCheckoutHours
+	}
+	return policy, hours
+}
+
+// autoCheckoutTime decides when an open shift is closed automatically. It
+// returns false while the shift should stay open.
+func autoCheckoutTime(attendance Attendance, open AttendanceSession, schedule WorkSchedule, loc *time.Location, now time.Time) (time.Time, bool) {
+	day, err := parseDate(string(attendance.Date), loc)
+	if err != nil {
+		return time.Time{}, false
+	}
+	grace := time.Duration(autoCheckoutGraceHours) * time.Hour
+
+	// Sessions opened after the scheduled end, like an evening re-check-in,
+	// get their grace period counted from when they started
+	end := maxTime(open.StartedAt, schedule.endOn(day))
+
+	var closeAt time.Time
+	policy, hours := schedule.autoCheckoutPolicy()
+	switch policy {
+	case autoCheckoutOff:
+		return time.Time{}, false
+	case autoCheckoutMaxHours:
+		closeAt = open.StartedAt.Add(time.Duration(hours) * time.Hour)
+		if now.Before(closeAt) {
+			return time.Time{}, false
+		}
+	case autoCheckoutFlag:
+		if now.Before(end.Add(grace)) {
+			return time.Time{}, false
+		}
+		closeAt = open.StartedAt
+	default:
+		closeAt = end
+		if now.Before(closeAt.Add(grace)) {
+			return time.Time{}, false
+		}
+	}
+	return closeAt, true
+}
+
+// autoCheckout closes shifts that were never checked out, according to each
+// user's schedule policy, and notifies the user so they can file a correction.
+func autoCheckout(now time.Time) {
+	var attendances []Attendance
+	if err := db.Preload("User").Where("check_in IS NOT NULL AND check_out IS NULL").Find(&attendances).Error; err != nil {
+		log.Printf("auto-checkout: failed to load open attendance: %v", err)
+		return
+	}
+
+	for _, attendance := range attendances {
+		if err := autoCloseAttendance(attendance, now); err != nil {
+			log.Printf("auto-checkout: attendance %d: %v", attendance.ID, err)
+		}
+	}
+}
+
+func autoCloseAttendance(attendance Attendance, now time.Time) error {
+	user := attendance.User
+	loc := userLocation(user)
+	schedule := scheduleFor(user)
+
+	sessions, err := loadSessions(db, attendance.ID)
+	if err != nil {
+		return err
+	}
+	open := openSession(sessions)
+	if open == nil {
+		return nil
+	}
+
+	closeAt, due := autoCheckoutTime(attendance, *open, schedule, loc, now)
+	if !due {
+		return nil
+	}
+
+	// An approved timesheet freezes the day; an admin has to reopen it
+	// before the shift can be closed
+	if isPeriodLocked(user.ID, string(attendance.Date), string(attendance.Date)) {
+		return nil
+	}
+
+	policy, _ := schedule.autoCheckoutPolicy()
+	flagged := policy == autoCheckoutFlag
+
+	before := snapshotAttendance(attendance)
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := endSession(tx, open, closeAt); err != nil {
+			return err
+		}
+
+		attendance.AutoClosed = true
+		attendance.NeedsReview = flagged
+		attendance.Notes = appendNote(attendance.Notes, "Checked out automatically")
+		if err := recomputeAttendance(tx, &attendance, user); err != nil {
+			return err
+		}
+		if err := logAttendanceChange(tx, attendance.ID, nil, "auto_checkout", "Policy "+policy, before, snapshotAttendance(attendance)); err != nil {
+			return err
+		}
+
+		message := fmt.Sprintf("You did not check out of your shift on %s. It was closed at %s. If this is wrong, please submit a correction.",
+			attendance.Date, closeAt.In(loc).Format("15:04"))
+		if flagged {
+			message = fmt.Sprintf("You did not check out of your shift on %s. It has been flagged for review; please submit a correction with your check-out time.",
+				attendance.Date)
+		}
+		return notify(tx, user.ID, "auto_checkout", "Missing check-out", message, &attendance.ID)
+	})
+}
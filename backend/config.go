@@ -0,0 +1,175 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultSchedule is the organization-wide work schedule. It applies to every
+// user without a department or personal override.
+var defaultSchedule = WorkSchedule{
+	Name:               "default",
+	StartTime:          "09:00",
+	EndTime:            "17:00",
+	GracePeriodMinutes: 15,
+	HalfDayMinutes:     240,
+	WorkingDays:        "1,2,3,4,5",
+	AutoCheckoutPolicy: autoCheckoutScheduledEnd,
+	AutoCheckoutHours:  12,
+}
+
+// loadConfig reads runtime settings from the environment. It must run after
+// the .env file has been loaded.
+func loadConfig() {
+	defaultSchedule.StartTime = getEnv("WORK_START_TIME", defaultSchedule.StartTime)
+	defaultSchedule.EndTime = getEnv("WORK_END_TIME", defaultSchedule.EndTime)
+	defaultSchedule.GracePeriodMinutes = getEnvInt("GRACE_PERIOD_MINUTES", defaultSchedule.GracePeriodMinutes)
+	defaultSchedule.HalfDayMinutes = getEnvInt("HALF_DAY_MINUTES", defaultSchedule.HalfDayMinutes)
+	defaultSchedule.WorkingDays = getEnv("WORKING_DAYS", defaultSchedule.WorkingDays)
+	defaultSchedule.AutoCheckoutPolicy = getEnv("AUTO_CHECKOUT_POLICY", defaultSchedule.AutoCheckoutPolicy)
+	defaultSchedule.AutoCheckoutHours = getEnvInt("AUTO_CHECKOUT_HOURS", defaultSchedule.AutoCheckoutHours)
+
+	if err := defaultSchedule.validate(); err != nil {
+		log.Fatal("Invalid default work schedule: ", err)
+	}
+
+	timezone := getEnv("DEFAULT_TIMEZONE", "UTC")
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		log.Fatal("Invalid DEFAULT_TIMEZONE: ", err)
+	}
+	defaultLocation = loc
+
+	if secret := getEnv("JWT_SECRET", ""); secret != "" {
+		jwtSecret = []byte(secret)
+	} else {
+		log.Println("JWT_SECRET is not set, using an insecure default")
+	}
+	accessTokenTTL = time.Duration(getEnvInt("ACCESS_TOKEN_TTL_MINUTES", int(accessTokenTTL/time.Minute))) * time.Minute
+	refreshTokenTTL = time.Duration(getEnvInt("REFRESH_TOKEN_TTL_DAYS", int(refreshTokenTTL/(24*time.Hour)))) * 24 * time.Hour
+	if accessTokenTTL <= 0 || refreshTokenTTL <= 0 {
+		log.Fatal("Invalid ACCESS_TOKEN_TTL_MINUTES or REFRESH_TOKEN_TTL_DAYS, expected positive values")
+	}
+
+	passwordPolicy.MinLength = getEnvInt("PASSWORD_MIN_LENGTH", passwordPolicy.MinLength)
+	passwordPolicy.RequireUpper = getEnvBool("PASSWORD_REQUIRE_UPPERCASE", passwordPolicy.RequireUpper)
+	passwordPolicy.RequireLower = getEnvBool("PASSWORD_REQUIRE_LOWERCASE", passwordPolicy.RequireLower)
+	passwordPolicy.RequireDigit = getEnvBool("PASSWORD_REQUIRE_DIGIT", passwordPolicy.RequireDigit)
+	passwordPolicy.RequireSymbol = getEnvBool("PASSWORD_REQUIRE_SYMBOL", passwordPolicy.RequireSymbol)
+	passwordPolicy.RejectCommon = getEnvBool("PASSWORD_REJECT_COMMON", passwordPolicy.RejectCommon)
+	passwordPolicy.HistorySize = getEnvInt("PASSWORD_HISTORY", passwordPolicy.HistorySize)
+	if path := getEnv("PASSWORD_BLOCKLIST_FILE", ""); path != "" {
+		if err := loadPasswordBlocklist(path); err != nil {
+			log.Fatal("Invalid PASSWORD_BLOCKLIST_FILE: ", err)
+		}
+	}
+
+	totpIssuer = getEnv("TOTP_ISSUER", totpIssuer)
+	requireAdminTwoFactor = getEnvBool("REQUIRE_ADMIN_2FA", requireAdminTwoFactor)
+
+	passwordResetTTL = time.Duration(getEnvInt("PASSWORD_RESET_TTL_MINUTES", int(passwordResetTTL/time.Minute))) * time.Minute
+	if passwordResetTTL <= 0 {
+		log.Fatal("Invalid PASSWORD_RESET_TTL_MINUTES, expected a positive number of minutes")
+	}
+
+	appURL = strings.TrimRight(getEnv("APP_URL", appURL), "/")
+	mailFrom = getEnv("MAIL_FROM", mailFrom)
+	mailDriver := getEnv("MAIL_DRIVER", "log")
+	if mailer, err = newMailer(mailDriver); err != nil {
+		log.Fatal("Invalid mail settings: ", err)
+	}
+	// The log and file drivers keep live password reset links on the server,
+	// which is only acceptable in development
+	if mailDriver != "smtp" && gin.Mode() != gin.DebugMode {
+		log.Printf("WARNING: MAIL_DRIVER is %s, so emails are not delivered and password reset links are written to the server; set MAIL_DRIVER=smtp in production", mailDriver)
+	}
+
+	schedulerEnabled = getEnvBool("SCHEDULER_ENABLED", schedulerEnabled)
+	autoCheckoutGraceHours = getEnvInt("AUTO_CHECKOUT_GRACE_HOURS", autoCheckoutGraceHours)
+
+	payrollPeriodDays = getEnvInt("PAYROLL_PERIOD_DAYS", payrollPeriodDays)
+	if payrollPeriodDays <= 0 {
+		log.Fatal("Invalid PAYROLL_PERIOD_DAYS, expected a positive number of days")
+	}
+	if path := getEnv("PAYROLL_LAYOUT_FILE", ""); path != "" {
+		if err := loadPayrollLayouts(path); err != nil {
+			log.Fatal("Invalid PAYROLL_LAYOUT_FILE: ", err)
+		}
+	}
+
+	timesheetPeriod = getEnv("TIMESHEET_PERIOD", timesheetPeriod)
+	if timesheetPeriod != timesheetWeekly && timesheetPeriod != timesheetMonthly {
+		log.Fatal("Invalid TIMESHEET_PERIOD, expected weekly or monthly: ", timesheetPeriod)
+	}
+
+	overtimeRules.DailyThresholdMinutes = getEnvInt("OVERTIME_DAILY_THRESHOLD_MINUTES", overtimeRules.DailyThresholdMinutes)
+	overtimeRules.WeeklyThresholdMinutes = getEnvInt("OVERTIME_WEEKLY_THRESHOLD_MINUTES", overtimeRules.WeeklyThresholdMinutes)
+	overtimeRules.Multiplier = getEnvFloat("OVERTIME_MULTIPLIER", overtimeRules.Multiplier)
+	overtimeRules.WeekendMultiplier = getEnvFloat("OVERTIME_WEEKEND_MULTIPLIER", overtimeRules.WeekendMultiplier)
+	overtimeRules.HolidayMultiplier = getEnvFloat("OVERTIME_HOLIDAY_MULTIPLIER", overtimeRules.HolidayMultiplier)
+	overtimeRules.RequiresApproval = getEnvBool("OVERTIME_REQUIRES_APPROVAL", overtimeRules.RequiresApproval)
+
+	alertRules.LateThreshold = getEnvInt("ALERT_LATE_THRESHOLD", alertRules.LateThreshold)
+	alertRules.LateWindowDays = getEnvInt("ALERT_LATE_WINDOW_DAYS", alertRules.LateWindowDays)
+	alertRules.WeekdayAbsenceThreshold = getEnvInt("ALERT_WEEKDAY_ABSENCE_THRESHOLD", alertRules.WeekdayAbsenceThreshold)
+	alertRules.WeekdayAbsenceWindowDays = getEnvInt("ALERT_WEEKDAY_ABSENCE_WINDOW_DAYS", alertRules.WeekdayAbsenceWindowDays)
+	alertRules.DriftMinutesPerWeek = getEnvInt("ALERT_DRIFT_MINUTES_PER_WEEK", alertRules.DriftMinutesPerWeek)
+	alertRules.DriftWindowDays = getEnvInt("ALERT_DRIFT_WINDOW_DAYS", alertRules.DriftWindowDays)
+	alertRules.DriftMinDays = getEnvInt("ALERT_DRIFT_MIN_DAYS", alertRules.DriftMinDays)
+
+	payPeriodStart = getEnv("PAY_PERIOD_START", payPeriodStart)
+	if _, err := parseDate(payPeriodStart, time.UTC); err != nil {
+		log.Fatal("Invalid PAY_PERIOD_START, expected YYYY-MM-DD: ", err)
+	}
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Invalid value for %s: %q, using %t", key, value, fallback)
+		return fallback
+	}
+	return parsed
+}
+
+func getEnvFloat(key string, fallback float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("Invalid value for %s: %q, using %g", key, value, fallback)
+		return fallback
+	}
+	return parsed
+}
+
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func getEnvInt(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid value for %s: %q, using %d", key, value, fallback)
+		return fallback
+	}
+	return parsed
+}
@@ -0,0 +1,389 @@
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Scan implements sql.Scanner for snapshots stored as JSON text.
+func (s *AttendanceSnapshot) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, s)
+	case string:
+		return json.Unmarshal([]byte(v), s)
+	default:
+		return fmt.Errorf("cannot scan %T into AttendanceSnapshot", value)
+	}
+}
+
+// Value implements driver.Valuer.
+func (s AttendanceSnapshot) Value() (driver.Value, error) {
+	data, err := json.Marshal(s)
+	return string(data), err
+}
+
+// snapshotAttendance copies the editable values of an attendance record.
+func snapshotAttendance(attendance Attendance) *AttendanceSnapshot {
+	return &AttendanceSnapshot{
+		Date:          attendance.Date,
+		CheckIn:       attendance.CheckIn,
+		CheckOut:      attendance.CheckOut,
+		Status:        attendance.Status,
+		Notes:         attendance.Notes,
+		WorkedMinutes: attendance.WorkedMinutes,
+		BreakMinutes:  attendance.BreakMinutes,
+	}
+}
+
+func validAttendanceStatus(status string) bool {
+	switch status {
+	case "present", "absent", "late", "half_day":
+		return true
+	}
+	return false
+}
+
+// checkCorrection validates proposed times against the user's current record
+// of the day, which is nil if there is none.
+func checkCorrection(user User, date string, attendance *Attendance, checkIn, checkOut *time.Time) (int, error) {
+	if isPeriodLocked(user.ID, date, date) {
+		return http.StatusConflict, errPeriodLocked
+	}
+	if checkIn == nil && checkOut == nil {
+		return http.StatusOK, nil
+	}
+
+	var excludeID uint
+	if attendance != nil {
+		excludeID = attendance.ID
+		if checkIn == nil {
+			checkIn = attendance.CheckIn
+		}
+		if checkOut == nil {
+			checkOut = attendance.CheckOut
+		}
+	}
+
+	if err := validateAttendanceTimes(user, date, checkIn, checkOut); err != nil {
+		return http.StatusBadRequest, err
+	}
+	if err := checkAttendanceConflicts(user, date, checkIn, checkOut, excludeID); err != nil {
+		return http.StatusConflict, err
+	}
+	return http.StatusOK, nil
+}
+
+// findAttendance returns the user's attendance record for a date, or nil.
+func findAttendance(tx *gorm.DB, userID uint, date string) (*Attendance, error) {
+	var attendance Attendance
+	err := tx.Where("user_id = ? AND date = ?", userID, date).First(&attendance).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &attendance, nil
+}
+
+func submitCorrectionRequest(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var user User
+	if err := db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	var req CreateCorrectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	loc := userLocation(user)
+	now := time.Now()
+	if _, err := parseDate(req.Date, loc); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date, expected YYYY-MM-DD"})
+		return
+	}
+	if req.Date > now.In(loc).Format(dateLayout) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Future days cannot be corrected"})
+		return
+	}
+	if req.CheckIn == nil && req.CheckOut == nil && req.Status == "" && req.Notes == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nothing to correct"})
+		return
+	}
+	if req.Status != "" && !validAttendanceStatus(req.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Status must be present, absent, late or half_day"})
+		return
+	}
+
+	attendance, err := findAttendance(db, user.ID, req.Date)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch attendance"})
+		return
+	}
+	if status, err := checkCorrection(user, req.Date, attendance, req.CheckIn, req.CheckOut); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	var pending int64
+	db.Model(&CorrectionRequest{}).
+		Where("user_id = ? AND date = ? AND status = ?", user.ID, req.Date, "pending").
+		Count(&pending)
+	if pending > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "A correction for this day is already pending"})
+		return
+	}
+
+	correction := CorrectionRequest{
+		UserID:           user.ID,
+		Date:             Date(req.Date),
+		ProposedCheckIn:  req.CheckIn,
+		ProposedCheckOut: req.CheckOut,
+		ProposedStatus:   req.Status,
+		ProposedNotes:    req.Notes,
+		Reason:           req.Reason,
+		Status:           "pending",
+	}
+	if attendance != nil {
+		correction.AttendanceID = &attendance.ID
+	}
+
+	if err := db.Create(&correction).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create correction request"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, correction)
+}
+
+func getMyCorrectionRequests(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	page, limit := parsePagination(c)
+	offset := (page - 1) * limit
+
+	query := db.Model(&CorrectionRequest{}).Where("user_id = ?", userID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var corrections []CorrectionRequest
+	if err := query.Order("created_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&corrections).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch correction requests"})
+		return
+	}
+
+	c.JSON(http.StatusOK, paginated(corrections, page, limit, total))
+}
+
+func cancelCorrectionRequest(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var correction CorrectionRequest
+	if err := db.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&correction).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Correction request not found"})
+		return
+	}
+
+	if correction.Status != "pending" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only pending requests can be cancelled"})
+		return
+	}
+
+	correction.Status = "cancelled"
+	if err := db.Model(&correction).Update("status", correction.Status).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel correction request"})
+		return
+	}
+
+	c.JSON(http.StatusOK, correction)
+}
+
+func getAllCorrectionRequests(c *gin.Context) {
+	page, limit := parsePagination(c)
+	offset := (page - 1) * limit
+
+	query := db.Model(&CorrectionRequest{})
+
+	// Apply filters
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if userIDParam := c.Query("user_id"); userIDParam != "" {
+		if userID, err := strconv.ParseUint(userIDParam, 10, 32); err == nil {
+			query = query.Where("user_id = ?", userID)
+		}
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var corrections []CorrectionRequest
+	if err := query.Preload("User").
+		Order("created_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&corrections).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch correction requests"})
+		return
+	}
+
+	c.JSON(http.StatusOK, paginated(corrections, page, limit, total))
+}
+
+func approveCorrectionRequest(c *gin.Context) {
+	reviewCorrectionRequest(c, "approved")
+}
+
+func rejectCorrectionRequest(c *gin.Context) {
+	reviewCorrectionRequest(c, "rejected")
+}
+
+// reviewCorrectionRequest approves or rejects a pending correction. Approval
+// applies the proposed values to the day's attendance, creating the record
+// if needed, and re-derives its status unless a status was proposed.
+var errCorrectionNotPending = errors.New("Only pending requests can be reviewed")
+
+func reviewCorrectionRequest(c *gin.Context, status string) {
+	reviewerID, _ := c.Get("user_id")
+
+	var req ReviewRequest
+	if err := bindOptionalJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var correction CorrectionRequest
+	if err := db.Preload("User").First(&correction, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Correction request not found"})
+		return
+	}
+
+	if correction.Status != "pending" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errCorrectionNotPending.Error()})
+		return
+	}
+
+	// The record may have changed since the request was made
+	attendance, err := findAttendance(db, correction.UserID, string(correction.Date))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch attendance"})
+		return
+	}
+	if status == "approved" {
+		if status, err := checkCorrection(correction.User, string(correction.Date), attendance, correction.ProposedCheckIn, correction.ProposedCheckOut); err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	now := time.Now()
+	reviewer := reviewerID.(uint)
+	correction.Status = status
+	correction.ReviewedBy = &reviewer
+	correction.ReviewedAt = &now
+	correction.ReviewNote = req.Note
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Claim the request so that a concurrent review cannot apply it too
+		result := tx.Model(&CorrectionRequest{}).
+			Where("id = ? AND status = ?", correction.ID, "pending").
+			Update("status", status)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errCorrectionNotPending
+		}
+
+		if status == "approved" {
+			if err := applyCorrection(tx, &correction, attendance, reviewer); err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&correction).
+			Select("status", "reviewed_by", "reviewed_at", "review_note", "attendance_id", "before", "after").
+			Updates(&correction).Error; err != nil {
+			return err
+		}
+
+		message := fmt.Sprintf("Your correction for %s was %s.", correction.Date, status)
+		if req.Note != "" {
+			message += " " + req.Note
+		}
+		return notify(tx, correction.UserID, "correction_reviewed", "Attendance correction "+status, message, correction.AttendanceID)
+	})
+	if errors.Is(err, errCorrectionNotPending) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to review correction request"})
+		return
+	}
+
+	c.JSON(http.StatusOK, correction)
+}
+
+// applyCorrection applies an approved correction to attendance, the current
+// record of the day or nil, and records the values before and after.
+func applyCorrection(tx *gorm.DB, correction *CorrectionRequest, attendance *Attendance, reviewer uint) error {
+	if attendance == nil {
+		attendance = &Attendance{
+			UserID: correction.UserID,
+			Date:   correction.Date,
+			Status: "absent",
+		}
+		if err := tx.Create(attendance).Error; err != nil {
+			return err
+		}
+	} else {
+		correction.Before = snapshotAttendance(*attendance)
+	}
+
+	if err := retimeSessions(tx, attendance.ID, correction.ProposedCheckIn, correction.ProposedCheckOut); err != nil {
+		return err
+	}
+
+	if correction.ProposedNotes != nil {
+		attendance.Notes = *correction.ProposedNotes
+	}
+	if correction.ProposedCheckOut != nil {
+		attendance.AutoClosed = false
+	}
+	attendance.NeedsReview = false
+	if err := recomputeAttendance(tx, attendance, correction.User); err != nil {
+		return err
+	}
+
+	if correction.ProposedStatus != "" && correction.ProposedStatus != attendance.Status {
+		attendance.Status = correction.ProposedStatus
+		if err := tx.Omit(clause.Associations).Save(attendance).Error; err != nil {
+			return err
+		}
+	}
+
+	correction.AttendanceID = &attendance.ID
+	correction.After = snapshotAttendance(*attendance)
+	return logAttendanceChange(tx, attendance.ID, &reviewer, "correction", correction.Reason, correction.Before, correction.After)
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// histogramBucketSizes are the allowed check-in histogram bucket widths in
+// minutes; they divide a day evenly.
+var histogramBucketSizes = map[int]bool{5: true, 10: true, 15: true, 30: true, 60: true}
+
+// dashboardNotModified sets the caching headers of a dashboard response and
+// reports whether the client's copy is still current, in which case a 304
+// has been sent. The ETag changes with any attendance record or user being
+// created, updated or deleted, since the responses join users for their
+// department and timezone, and with the day, since default ranges end today.
+func dashboardNotModified(c *gin.Context) bool {
+	type version struct {
+		Updated sql.NullString
+		Deleted sql.NullString
+		Count   int64
+	}
+	var attendance, users version
+	for _, table := range []struct {
+		model   interface{}
+		version *version
+	}{{&Attendance{}, &attendance}, {&User{}, &users}} {
+		if err := db.Unscoped().Model(table.model).
+			Select("MAX(updated_at) AS updated, MAX(deleted_at) AS deleted, COUNT(*) AS count").
+			Scan(table.version).Error; err != nil {
+			// Without a version the response is simply not cached
+			return false
+		}
+	}
+
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%d|%s|%s|%d|%s|%s",
+		attendance.Updated.String, attendance.Deleted.String, attendance.Count,
+		users.Updated.String, users.Deleted.String, users.Count,
+		time.Now().In(defaultLocation).Format(dateLayout), c.Request.URL.RawQuery)))
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "private, no-cache")
+	if match := c.GetHeader("If-None-Match"); match == etag || match == "*" {
+		c.Status(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+// dashboardQuery selects attendance joined with users in the scope of the
+// request: one user with user_id, one department with department, or
+// otherwise the whole company.
+func dashboardQuery(c *gin.Context, start, end time.Time) *gorm.DB {
+	query := db.Model(&Attendance{}).
+		Joins("JOIN users ON users.id = attendances.user_id").
+		Where("attendances.date BETWEEN ? AND ?", start.Format(dateLayout), end.Format(dateLayout))
+	if userIDParam := c.Query("user_id"); userIDParam != "" {
+		if userID, err := strconv.ParseUint(userIDParam, 10, 32); err == nil {
+			query = query.Where("attendances.user_id = ?", userID)
+		}
+	}
+	if department := c.Query("department"); department != "" {
+		query = query.Where("users.department = ?", department)
+	}
+	return query
+}
+
+// getDashboardHeatmap returns per-day record counts by status for a
+// calendar heatmap, over the past year by default.
+func getDashboardHeatmap(c *gin.Context) {
+	today := time.Now().In(defaultLocation)
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, defaultLocation)
+	start, end, err := parseDateRange(c, defaultLocation, today.AddDate(-1, 0, 1), today)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if dashboardNotModified(c) {
+		return
+	}
+
+	var counts []struct {
+		Day    string
+		Status string
+		Count  int
+	}
+	if err := dashboardQuery(c, start, end).
+		Select("date(attendances.date) AS day, attendances.status AS status, COUNT(*) AS count").
+		Group("day, attendances.status").
+		Order("day").
+		Scan(&counts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate heatmap"})
+		return
+	}
+
+	days := []HeatmapDay{}
+	for _, count := range counts {
+		if len(days) == 0 || days[len(days)-1].Date != count.Day {
+			days = append(days, HeatmapDay{Date: count.Day})
+		}
+		day := &days[len(days)-1]
+		day.Total += count.Count
+		switch count.Status {
+		case "present":
+			day.Present = count.Count
+		case "late":
+			day.Late = count.Count
+		case "half_day":
+			day.HalfDay = count.Count
+		case "absent":
+			day.Absent = count.Count
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"start_date": start.Format(dateLayout),
+		"end_date":   end.Format(dateLayout),
+		"data":       days,
+	})
+}
+
+// getDashboardHistogram counts check-ins by local time of day, in buckets
+// of bucket_minutes, over the past 30 days by default. Buckets between the
+// earliest and latest check-in are all present, empty ones with a zero.
+func getDashboardHistogram(c *gin.Context) {
+	bucketSize := 15
+	if raw := c.Query("bucket_minutes"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || !histogramBucketSizes[parsed] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bucket_minutes, expected 5, 10, 15, 30 or 60"})
+			return
+		}
+		bucketSize = parsed
+	}
+
+	today := time.Now().In(defaultLocation)
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, defaultLocation)
+	start, end, err := parseDateRange(c, defaultLocation, today.AddDate(0, 0, -29), today)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if dashboardNotModified(c) {
+		return
+	}
+
+	// Minutes from UTC midnight of the attendance date; the offset of each
+	// timezone on that date turns them into local times below
+	var counts []struct {
+		Timezone string
+		Day      string
+		Minute   int
+		Count    int
+	}
+	if err := dashboardQuery(c, start, end).
+		Select("users.timezone AS timezone, date(attendances.date) AS day, " +
+			"CAST(ROUND((julianday(attendances.check_in) - julianday(attendances.date)) * 1440) AS INTEGER) AS minute, COUNT(*) AS count").
+		Where("attendances.check_in IS NOT NULL").
+		Group("users.timezone, day, minute").
+		Scan(&counts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate histogram"})
+		return
+	}
+
+	locations := map[string]*time.Location{}
+	buckets := map[int]int{}
+	for _, count := range counts {
+		loc, ok := locations[count.Timezone]
+		if !ok {
+			loc = userLocation(User{Timezone: count.Timezone})
+			locations[count.Timezone] = loc
+		}
+		day, err := parseDate(count.Day, loc)
+		if err != nil {
+			continue
+		}
+		_, offset := day.Zone()
+		minute := ((count.Minute+offset/60)%1440 + 1440) % 1440
+		buckets[minute/bucketSize*bucketSize] += count.Count
+	}
+
+	starts := make([]int, 0, len(buckets))
+	for bucket := range buckets {
+		starts = append(starts, bucket)
+	}
+	sort.Ints(starts)
+
+	histogram := []HistogramBucket{}
+	if len(starts) > 0 {
+		for bucket := starts[0]; bucket <= starts[len(starts)-1]; bucket += bucketSize {
+			histogram = append(histogram, HistogramBucket{Start: formatClockTime(bucket), Count: buckets[bucket]})
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"start_date":     start.Format(dateLayout),
+		"end_date":       end.Format(dateLayout),
+		"bucket_minutes": bucketSize,
+		"data":           histogram,
+	})
+}
+
+// getDashboardTrends returns weekly attendance figures, Monday to Sunday,
+// with the change from the week before. By default it covers the last 12
+// weeks including the current one; every week appears, empty ones with
+// zeros.
+func getDashboardTrends(c *gin.Context) {
+	today := time.Now().In(defaultLocation)
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, defaultLocation)
+	start, end, err := parseDateRange(c, defaultLocation, weekStart(today).AddDate(0, 0, -77), today)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	start = weekStart(start)
+	if dashboardNotModified(c) {
+		return
+	}
+
+	var groups []struct {
+		Week     string
+		Records  int
+		Attended int
+		Late     int
+		Absent   int
+		Worked   int
+	}
+	if err := dashboardQuery(c, start, end).
+		Select(analyticsPeriods["week"] + " AS week, COUNT(*) AS records, " +
+			"SUM(CASE WHEN attendances.check_in IS NOT NULL THEN 1 ELSE 0 END) AS attended, " +
+			"SUM(CASE WHEN attendances.status = 'late' THEN 1 ELSE 0 END) AS late, " +
+			"SUM(CASE WHEN attendances.status = 'absent' THEN 1 ELSE 0 END) AS absent, " +
+			"COALESCE(SUM(attendances.worked_minutes), 0) AS worked").
+		Group("week").
+		Scan(&groups).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate trends"})
+		return
+	}
+	byWeek := make(map[string]int, len(groups))
+	for i, group := range groups {
+		byWeek[group.Week] = i
+	}
+
+	weeks := []TrendWeek{}
+	for week := start; !week.After(end); week = week.AddDate(0, 0, 7) {
+		point := TrendWeek{Week: week.Format(dateLayout)}
+		if i, ok := byWeek[point.Week]; ok {
+			group := groups[i]
+			point.Records = group.Records
+			point.AttendedDays = group.Attended
+			point.LateDays = group.Late
+			point.AbsentDays = group.Absent
+			point.WorkedHours = minutesToHours(group.Worked)
+			if group.Records > 0 {
+				point.AttendanceRate = percentage(group.Attended, group.Records)
+			}
+			if group.Attended > 0 {
+				point.LateRate = percentage(group.Late, group.Attended)
+			}
+		}
+		if len(weeks) > 0 {
+			previous := weeks[len(weeks)-1]
+			attendanceChange := math.Round((point.AttendanceRate-previous.AttendanceRate)*100) / 100
+			lateChange := math.Round((point.LateRate-previous.LateRate)*100) / 100
+			point.AttendanceRateChange = &attendanceChange
+			point.LateRateChange = &lateChange
+		}
+		weeks = append(weeks, point)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"start_date": start.Format(dateLayout),
+		"end_date":   end.Format(dateLayout),
+		"data":       weeks,
+	})
+}
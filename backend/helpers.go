@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bindOptionalJSON binds a JSON body like ShouldBindJSON but accepts an empty body.
+func bindOptionalJSON(c *gin.Context, obj interface{}) error {
+	if err := c.ShouldBindJSON(obj); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// parsePagination reads the page and limit query parameters used by list endpoints.
+func parsePagination(c *gin.Context) (page, limit int) {
+	page = 1
+	limit = 10
+
+	if p := c.Query("page"); p != "" {
+		if parsed, err := strconv.Atoi(p); err == nil && parsed > 0 {
+			page = parsed
+		}
+	}
+
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 100 {
+			limit = parsed
+		}
+	}
+
+	return page, limit
+}
+
+// paginated wraps a page of results in the response shape used by list endpoints.
+func paginated(data interface{}, page, limit int, total int64) gin.H {
+	return gin.H{
+		"data": data,
+		"pagination": gin.H{
+			"page":        page,
+			"limit":       limit,
+			"total":       total,
+			"total_pages": (total + int64(limit) - 1) / int64(limit),
+		},
+	}
+}
+
+// parseDateRange reads the start_date and end_date query parameters in loc,
+// falling back to start and end when they are absent.
+func parseDateRange(c *gin.Context, loc *time.Location, start, end time.Time) (time.Time, time.Time, error) {
+	for param, value := range map[string]*time.Time{"start_date": &start, "end_date": &end} {
+		if raw := c.Query(param); raw != "" {
+			parsed, err := parseDate(raw, loc)
+			if err != nil {
+				return start, end, errors.New("Invalid " + param + ", expected YYYY-MM-DD")
+			}
+			*value = parsed
+		}
+	}
+	if end.Before(start) {
+		return start, end, errors.New("End date must not be before start date")
+	}
+	return start, end, nil
+}
+
+// csvText keeps spreadsheet software from reading a text cell of a CSV
+// export as a formula, by prefixing values that start like one with a quote.
+func csvText(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// formatClockTime formats minutes past midnight as HH:MM, wrapping around
+// at a day's length.
+func formatClockTime(minutes int) string {
+	minutes = (minutes%1440 + 1440) % 1440
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
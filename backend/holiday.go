@@ -0,0 +1,417 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// holidaysFor returns the holidays that apply to user between start and end
+// (inclusive YYYY-MM-DD dates), keyed by date. Company-wide holidays apply to
+// everyone; department holidays only to that department.
+func holidaysFor(tx *gorm.DB, user User, start, end string) (map[string]Holiday, error) {
+	var holidays []Holiday
+	err := tx.Where("date BETWEEN ? AND ? AND (department = '' OR department = ?)", start, end, user.Department).
+		Find(&holidays).Error
+	if err != nil {
+		return nil, err
+	}
+
+	byDate := make(map[string]Holiday, len(holidays))
+	for _, holiday := range holidays {
+		byDate[string(holiday.Date)] = holiday
+	}
+	return byDate, nil
+}
+
+// isHoliday reports whether date is a holiday for user.
+func isHoliday(tx *gorm.DB, user User, date string) bool {
+	holidays, err := holidaysFor(tx, user, date, date)
+	return err == nil && len(holidays) > 0
+}
+
+func getHolidays(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var user User
+	if err := db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	// Default to the current year
+	year := c.DefaultQuery("year", time.Now().In(userLocation(user)).Format("2006"))
+
+	var holidays []Holiday
+	if err := db.Where("date LIKE ? AND (department = '' OR department = ?)", year+"-%", user.Department).
+		Order("date").
+		Find(&holidays).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch holidays"})
+		return
+	}
+
+	c.JSON(http.StatusOK, holidays)
+}
+
+func getAllHolidays(c *gin.Context) {
+	query := db.Model(&Holiday{})
+
+	if year := c.Query("year"); year != "" {
+		query = query.Where("date LIKE ?", year+"-%")
+	}
+	if department, ok := c.GetQuery("department"); ok {
+		query = query.Where("department = ?", department)
+	}
+
+	var holidays []Holiday
+	if err := query.Order("date, department").Find(&holidays).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch holidays"})
+		return
+	}
+
+	c.JSON(http.StatusOK, holidays)
+}
+
+func createHoliday(c *gin.Context) {
+	var req HolidayRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := parseDate(req.Date, time.UTC); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date, expected YYYY-MM-DD"})
+		return
+	}
+
+	holiday := Holiday{
+		Date:       Date(req.Date),
+		Name:       req.Name,
+		Department: req.Department,
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&holiday).Error; err != nil {
+			return err
+		}
+		return recomputeHolidayDates(tx, holiday.Department, []string{req.Date})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create holiday"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, holiday)
+}
+
+func updateHoliday(c *gin.Context) {
+	var holiday Holiday
+	if err := db.First(&holiday, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Holiday not found"})
+		return
+	}
+
+	var req HolidayRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := parseDate(req.Date, time.UTC); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date, expected YYYY-MM-DD"})
+		return
+	}
+
+	before := holiday
+	holiday.Date = Date(req.Date)
+	holiday.Name = req.Name
+	holiday.Department = req.Department
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&holiday).Error; err != nil {
+			return err
+		}
+		// Both the day the holiday left and the day it moved to change
+		if err := recomputeHolidayDates(tx, before.Department, []string{string(before.Date)}); err != nil {
+			return err
+		}
+		return recomputeHolidayDates(tx, holiday.Department, []string{req.Date})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update holiday"})
+		return
+	}
+
+	c.JSON(http.StatusOK, holiday)
+}
+
+func deleteHoliday(c *gin.Context) {
+	var holiday Holiday
+	if err := db.First(&holiday, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Holiday not found"})
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&holiday).Error; err != nil {
+			return err
+		}
+		return recomputeHolidayDates(tx, holiday.Department, []string{string(holiday.Date)})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete holiday"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Holiday deleted successfully"})
+}
+
+// importHolidays imports all-day events from an iCalendar file, sent either
+// as the "file" field of a multipart form or as the raw request body. The
+// optional department query parameter scopes the imported holidays.
+// Re-importing the same calendar updates holidays instead of duplicating them.
+func importHolidays(c *gin.Context) {
+	department := c.Query("department")
+
+	var reader io.Reader = c.Request.Body
+	if file, err := c.FormFile("file"); err == nil {
+		opened, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded file"})
+			return
+		}
+		defer opened.Close()
+		reader = opened
+	}
+
+	events, err := parseICS(reader)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// All or nothing, so that a failure does not leave half a calendar
+	created, updated := 0, 0
+	var dates []string
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for _, event := range events {
+			for d := event.start; d.Before(event.end); d = d.AddDate(0, 0, 1) {
+				date := d.Format(dateLayout)
+
+				var holiday Holiday
+				err := tx.Where("uid = ? AND date = ? AND department = ?", event.uid, date, department).First(&holiday).Error
+				if err == nil {
+					holiday.Name = event.summary
+					if err := tx.Save(&holiday).Error; err != nil {
+						return err
+					}
+					updated++
+					continue
+				}
+
+				holiday = Holiday{
+					Date:       Date(date),
+					Name:       event.summary,
+					Department: department,
+					UID:        event.uid,
+				}
+				if err := tx.Create(&holiday).Error; err != nil {
+					return err
+				}
+				dates = append(dates, date)
+				created++
+			}
+		}
+		if len(dates) == 0 {
+			return nil
+		}
+		return recomputeHolidayDates(tx, department, dates)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import holidays"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"created": created,
+		"updated": updated,
+	})
+}
+
+// recomputeHolidayDates brings attendance on dates whose holidays changed in
+// line with the calendar, for the users of department, or everyone when it
+// is empty.
+// Worked days are recomputed, automatic absences on what is now a holiday
+// are removed, and days that stopped being holidays are marked absent once
+// their shift has ended. Days in approved timesheets are left alone.
+func recomputeHolidayDates(tx *gorm.DB, department string, dates []string) error {
+	query := tx.Model(&User{})
+	if department != "" {
+		query = query.Where("department = ?", department)
+	}
+	var users []User
+	if err := query.Find(&users).Error; err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, user := range users {
+		loc := userLocation(user)
+		schedule := scheduleFor(user)
+
+		var records []Attendance
+		if err := tx.Where("user_id = ? AND date IN ?", user.ID, dates).Find(&records).Error; err != nil {
+			return err
+		}
+		byDate := make(map[string]*Attendance, len(records))
+		for i := range records {
+			byDate[string(records[i].Date)] = &records[i]
+		}
+
+		for _, date := range dates {
+			if isPeriodLocked(user.ID, date, date) {
+				continue
+			}
+			day, err := parseDate(date, loc)
+			if err != nil {
+				return err
+			}
+
+			record := byDate[date]
+			switch {
+			case record != nil && record.CheckIn != nil:
+				if err := recomputeAttendance(tx, record, user); err != nil {
+					return err
+				}
+				continue
+			case record != nil && record.Notes == autoAbsenceNote && isHoliday(tx, user, date):
+				// Deleted for good, so that the day can be marked again if
+				// the holiday is removed
+				if err := tx.Unscoped().Delete(record).Error; err != nil {
+					return err
+				}
+			case record == nil && !now.Before(schedule.endOn(day)):
+				if _, err := markAbsent(tx, user, schedule, day); err != nil {
+					return err
+				}
+			}
+
+			// Scheduled minutes and overtime of the week depend on the holiday
+			if err := recomputeOvertime(tx, user, date, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// maxHolidayEventDays is the longest calendar event importHolidays accepts;
+// longer ones are almost certainly not holidays.
+const maxHolidayEventDays = 31
+
+// icsEvent is an all-day calendar event covering [start, end).
+type icsEvent struct {
+	uid     string
+	summary string
+	start   time.Time
+	end     time.Time
+}
+
+// parseICS reads the VEVENTs of an iCalendar (RFC 5545) stream. Only the
+// date part of DTSTART and DTEND is used; a missing DTEND means a single day.
+func parseICS(r io.Reader) ([]icsEvent, error) {
+	// Unfold continuation lines, which start with a space or tab
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.New("Failed to read calendar")
+	}
+
+	var events []icsEvent
+	var current *icsEvent
+	for _, line := range lines {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		// Drop parameters such as ";VALUE=DATE"
+		name, _, _ = strings.Cut(strings.ToUpper(name), ";")
+
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			current = &icsEvent{}
+		case name == "END" && value == "VEVENT":
+			if current == nil {
+				continue
+			}
+			if current.start.IsZero() {
+				return nil, errors.New("Calendar event without DTSTART")
+			}
+			if !current.end.After(current.start) {
+				current.end = current.start.AddDate(0, 0, 1)
+			}
+			if current.end.After(current.start.AddDate(0, 0, maxHolidayEventDays)) {
+				return nil, fmt.Errorf("Calendar event %q is longer than %d days", current.summary, maxHolidayEventDays)
+			}
+			if current.uid == "" {
+				current.uid = current.start.Format(dateLayout) + "-" + current.summary
+			}
+			events = append(events, *current)
+			current = nil
+		case current == nil:
+			continue
+		case name == "RRULE":
+			return nil, errors.New("Recurring calendar events (RRULE) are not supported, export the calendar with the occurrences expanded")
+		case name == "UID":
+			current.uid = value
+		case name == "SUMMARY":
+			current.summary = unescapeICSText(value)
+		case name == "DTSTART", name == "DTEND":
+			date, err := parseICSDate(value)
+			if err != nil {
+				return nil, err
+			}
+			if name == "DTSTART" {
+				current.start = date
+			} else {
+				current.end = date
+			}
+		}
+	}
+
+	if len(events) == 0 {
+		return nil, errors.New("Calendar contains no events")
+	}
+	return events, nil
+}
+
+// parseICSDate parses the date part of an iCalendar DATE or DATE-TIME value.
+func parseICSDate(value string) (time.Time, error) {
+	if len(value) < 8 {
+		return time.Time{}, fmt.Errorf("Invalid calendar date %q", value)
+	}
+	date, err := time.Parse("20060102", value[:8])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Invalid calendar date %q", value)
+	}
+	return date, nil
+}
+
+func unescapeICSText(value string) string {
+	return strings.NewReplacer(`\n`, " ", `\N`, " ", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(value)
+}
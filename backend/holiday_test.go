@@ -0,0 +1,111 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseICS(t *testing.T) {
+	type event struct {
+		uid, summary, start, end string
+	}
+
+	tests := []struct {
+		name     string
+		calendar string
+		want     []event
+		wantErr  string
+	}{
+		{
+			name: "all-day events",
+			calendar: "BEGIN:VCALENDAR\r\n" +
+				"BEGIN:VEVENT\r\nUID:new-year\r\nDTSTART;VALUE=DATE:20270101\r\nDTEND;VALUE=DATE:20270102\r\nSUMMARY:New Year's Day\r\nEND:VEVENT\r\n" +
+				"BEGIN:VEVENT\r\nUID:christmas\r\nDTSTART;VALUE=DATE:20261225\r\nDTEND;VALUE=DATE:20261227\r\nSUMMARY:Christmas\r\nEND:VEVENT\r\n" +
+				"END:VCALENDAR\r\n",
+			want: []event{
+				{"new-year", "New Year's Day", "2027-01-01", "2027-01-02"},
+				{"christmas", "Christmas", "2026-12-25", "2026-12-27"},
+			},
+		},
+		{
+			name:     "missing DTEND is one day",
+			calendar: "BEGIN:VEVENT\nUID:a\nDTSTART:20261003\nSUMMARY:Unity Day\nEND:VEVENT\n",
+			want:     []event{{"a", "Unity Day", "2026-10-03", "2026-10-04"}},
+		},
+		{
+			name:     "date-time values keep the date",
+			calendar: "BEGIN:VEVENT\nUID:a\nDTSTART:20261003T000000Z\nDTEND:20261004T000000Z\nSUMMARY:Unity Day\nEND:VEVENT\n",
+			want:     []event{{"a", "Unity Day", "2026-10-03", "2026-10-04"}},
+		},
+		{
+			name:     "folded and escaped summary",
+			calendar: "BEGIN:VEVENT\nUID:a\nDTSTART:20261003\nSUMMARY:Day of German\n  Unity\\, observed\\; national\nEND:VEVENT\n",
+			want:     []event{{"a", "Day of German Unity, observed; national", "2026-10-03", "2026-10-04"}},
+		},
+		{
+			name:     "missing UID falls back to date and summary",
+			calendar: "BEGIN:VEVENT\nDTSTART:20261003\nSUMMARY:Unity Day\nEND:VEVENT\n",
+			want:     []event{{"2026-10-03-Unity Day", "Unity Day", "2026-10-03", "2026-10-04"}},
+		},
+		{
+			name:     "properties outside events are ignored",
+			calendar: "BEGIN:VCALENDAR\nPRODID:-//Test//EN\nSUMMARY:Not an event\nBEGIN:VEVENT\nUID:a\nDTSTART:20261003\nSUMMARY:Unity Day\nEND:VEVENT\nEND:VCALENDAR\n",
+			want:     []event{{"a", "Unity Day", "2026-10-03", "2026-10-04"}},
+		},
+		{
+			name:     "longest accepted event",
+			calendar: "BEGIN:VEVENT\nUID:a\nDTSTART:20260701\nDTEND:20260801\nSUMMARY:Summer\nEND:VEVENT\n",
+			want:     []event{{"a", "Summer", "2026-07-01", "2026-08-01"}},
+		},
+		{
+			name:     "event too long",
+			calendar: "BEGIN:VEVENT\nUID:a\nDTSTART:20260701\nDTEND:20260802\nSUMMARY:Summer\nEND:VEVENT\n",
+			wantErr:  "longer than 31 days",
+		},
+		{
+			name:     "recurring event",
+			calendar: "BEGIN:VEVENT\nUID:a\nDTSTART:20261225\nRRULE:FREQ=YEARLY\nSUMMARY:Christmas\nEND:VEVENT\n",
+			wantErr:  "RRULE",
+		},
+		{
+			name:     "missing DTSTART",
+			calendar: "BEGIN:VEVENT\nUID:a\nSUMMARY:Christmas\nEND:VEVENT\n",
+			wantErr:  "without DTSTART",
+		},
+		{
+			name:     "invalid date",
+			calendar: "BEGIN:VEVENT\nUID:a\nDTSTART:2026-12-25\nSUMMARY:Christmas\nEND:VEVENT\n",
+			wantErr:  "Invalid calendar date",
+		},
+		{
+			name:     "no events",
+			calendar: "BEGIN:VCALENDAR\nEND:VCALENDAR\n",
+			wantErr:  "no events",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := parseICS(strings.NewReader(tt.calendar))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(events) != len(tt.want) {
+				t.Fatalf("got %d events, want %d", len(events), len(tt.want))
+			}
+			for i, want := range tt.want {
+				got := event{events[i].uid, events[i].summary, events[i].start.Format(dateLayout), events[i].end.Format(dateLayout)}
+				if got != want {
+					t.Errorf("event %d = %+v, want %+v", i, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,385 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func floatPtr(v float64) *float64 { return &v }
+
+// defaultLeaveTypes are created on startup if missing.
+var defaultLeaveTypes = []LeaveType{
+	{Code: "vacation", Name: "Vacation", Paid: true, AccrualFrequency: accrualMonthly, AccrualDays: 1.25, CarryOverCap: floatPtr(5)},
+	{Code: "sick", Name: "Sick leave", Paid: true, AccrualFrequency: accrualYearly, AccrualDays: 10, CarryOverCap: floatPtr(0)},
+	{Code: "unpaid", Name: "Unpaid leave", Paid: false, AccrualFrequency: accrualNone},
+}
+
+var (
+	errLeaveNotPending    = errors.New("Only pending requests can be reviewed")
+	errLeaveStatusChanged = errors.New("Leave request was changed meanwhile, reload and try again")
+)
+
+func seedLeaveTypes() error {
+	for _, leaveType := range defaultLeaveTypes {
+		var existing LeaveType
+		err := db.Where("code = ?", leaveType.Code).First(&existing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if err := db.Create(&leaveType).Error; err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		// Types created before accrual policies existed get the default policy
+		if existing.AccrualFrequency == "" {
+			if err := db.Model(&existing).Select("accrual_frequency", "accrual_days", "carry_over_cap").Updates(leaveType).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// leaveDays counts the working days a leave request covers in the user's
+// schedule, not counting holidays. A half-day request counts as half a day.
+func leaveDays(user User, start, end time.Time, halfDay bool) float64 {
+	holidays, _ := holidaysFor(db, user, start.Format(dateLayout), end.Format(dateLayout))
+	schedule := scheduleFor(user)
+
+	days := 0.0
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		if _, holiday := holidays[d.Format(dateLayout)]; schedule.isWorkingDay(d) && !holiday {
+			days++
+		}
+	}
+	if halfDay && days > 0 {
+		return 0.5
+	}
+	return days
+}
+
+// approvedLeaveByDate returns the fraction of each working day in
+// [start, end] that the user is on approved leave, keyed by date. Holidays
+// are not leave days.
+func approvedLeaveByDate(tx *gorm.DB, user User, start, end time.Time) (map[string]float64, error) {
+	var requests []LeaveRequest
+	err := tx.Where("user_id = ? AND status = ? AND start_date <= ? AND end_date >= ?",
+		user.ID, "approved", end.Format(dateLayout), start.Format(dateLayout)).
+		Find(&requests).Error
+	if err != nil {
+		return nil, err
+	}
+
+	holidays, err := holidaysFor(tx, user, start.Format(dateLayout), end.Format(dateLayout))
+	if err != nil {
+		return nil, err
+	}
+
+	loc := userLocation(user)
+	schedule := scheduleFor(user)
+	byDate := make(map[string]float64)
+	for _, request := range requests {
+		from, _ := parseDate(string(request.StartDate), loc)
+		to, _ := parseDate(string(request.EndDate), loc)
+		if from.Before(start) {
+			from = start
+		}
+		if to.After(end) {
+			to = end
+		}
+		for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+			key := d.Format(dateLayout)
+			if _, holiday := holidays[key]; !schedule.isWorkingDay(d) || holiday {
+				continue
+			}
+			fraction := 1.0
+			if request.HalfDay {
+				fraction = 0.5
+			}
+			byDate[key] = min(byDate[key]+fraction, 1)
+		}
+	}
+	return byDate, nil
+}
+
+func getLeaveTypes(c *gin.Context) {
+	var leaveTypes []LeaveType
+	if err := db.Order("id").Find(&leaveTypes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch leave types"})
+		return
+	}
+
+	c.JSON(http.StatusOK, leaveTypes)
+}
+
+func submitLeaveRequest(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var req CreateLeaveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user User
+	if err := db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	var leaveType LeaveType
+	if err := db.First(&leaveType, req.LeaveTypeID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid leave type"})
+		return
+	}
+
+	// Dates are calendar dates in the user's timezone
+	loc := userLocation(user)
+	if req.EndDate == "" {
+		req.EndDate = req.StartDate
+	}
+	startDate, err := parseDate(req.StartDate, loc)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start_date, expected YYYY-MM-DD"})
+		return
+	}
+	endDate, err := parseDate(req.EndDate, loc)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end_date, expected YYYY-MM-DD"})
+		return
+	}
+	if endDate.Before(startDate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "end_date must not be before start_date"})
+		return
+	}
+	if req.HalfDay && !endDate.Equal(startDate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A half-day request must cover a single day"})
+		return
+	}
+
+	days := leaveDays(user, startDate, endDate, req.HalfDay)
+	if days == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Leave range contains no working days"})
+		return
+	}
+
+	// Reject overlaps with pending or approved leave
+	var overlapping int64
+	db.Model(&LeaveRequest{}).
+		Where("user_id = ? AND status IN ? AND start_date <= ? AND end_date >= ?",
+			user.ID, []string{"pending", "approved"}, req.EndDate, req.StartDate).
+		Count(&overlapping)
+	if overlapping > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Leave overlaps an existing request"})
+		return
+	}
+
+	if isPeriodLocked(user.ID, req.StartDate, req.EndDate) {
+		c.JSON(http.StatusConflict, gin.H{"error": errPeriodLocked.Error()})
+		return
+	}
+
+	leave := LeaveRequest{
+		UserID:      user.ID,
+		LeaveTypeID: leaveType.ID,
+		StartDate:   Date(req.StartDate),
+		EndDate:     Date(req.EndDate),
+		HalfDay:     req.HalfDay,
+		Days:        days,
+		Reason:      req.Reason,
+		Status:      "pending",
+	}
+
+	if err := db.Create(&leave).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit leave request"})
+		return
+	}
+
+	if err := db.Preload("User").Preload("LeaveType").First(&leave, leave.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load leave request"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, leave)
+}
+
+func getMyLeaveRequests(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	query := db.Model(&LeaveRequest{}).Where("user_id = ?", userID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var requests []LeaveRequest
+	if err := query.Preload("LeaveType").Order("start_date DESC").Find(&requests).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch leave requests"})
+		return
+	}
+
+	c.JSON(http.StatusOK, requests)
+}
+
+func cancelLeaveRequest(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var leave LeaveRequest
+	if err := db.Preload("User").Preload("LeaveType").Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&leave).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Leave request not found"})
+		return
+	}
+
+	// Approved leave can only be cancelled before it starts
+	switch leave.Status {
+	case "pending":
+	case "approved":
+		today := time.Now().In(userLocation(leave.User)).Format(dateLayout)
+		if string(leave.StartDate) <= today {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Leave that has already started cannot be cancelled"})
+			return
+		}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only pending or approved leave can be cancelled"})
+		return
+	}
+
+	// Cancelled approved leave goes back into the balance
+	wasApproved := leave.Status == "approved"
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Only one of two concurrent cancels gets to refund the balance
+		result := tx.Model(&LeaveRequest{}).
+			Where("id = ? AND status = ?", leave.ID, leave.Status).
+			Update("status", "cancelled")
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errLeaveStatusChanged
+		}
+		if wasApproved {
+			return refundLeaveBalance(tx, leave)
+		}
+		return nil
+	})
+	if errors.Is(err, errLeaveStatusChanged) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel leave request"})
+		return
+	}
+	leave.Status = "cancelled"
+
+	c.JSON(http.StatusOK, leave)
+}
+
+func getAllLeaveRequests(c *gin.Context) {
+	page, limit := parsePagination(c)
+	offset := (page - 1) * limit
+
+	query := db.Model(&LeaveRequest{})
+
+	// Apply filters
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if userIDParam := c.Query("user_id"); userIDParam != "" {
+		if userID, err := strconv.ParseUint(userIDParam, 10, 32); err == nil {
+			query = query.Where("user_id = ?", userID)
+		}
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var requests []LeaveRequest
+	if err := query.Preload("User").Preload("LeaveType").
+		Order("created_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&requests).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch leave requests"})
+		return
+	}
+
+	c.JSON(http.StatusOK, paginated(requests, page, limit, total))
+}
+
+func approveLeaveRequest(c *gin.Context) {
+	reviewLeaveRequest(c, "approved")
+}
+
+func rejectLeaveRequest(c *gin.Context) {
+	reviewLeaveRequest(c, "rejected")
+}
+
+func reviewLeaveRequest(c *gin.Context, status string) {
+	reviewerID, _ := c.Get("user_id")
+
+	var req ReviewRequest
+	if err := bindOptionalJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var leave LeaveRequest
+	if err := db.Preload("User").Preload("LeaveType").First(&leave, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Leave request not found"})
+		return
+	}
+
+	if leave.Status != "pending" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errLeaveNotPending.Error()})
+		return
+	}
+
+	if status == "approved" && isPeriodLocked(leave.UserID, string(leave.StartDate), string(leave.EndDate)) {
+		c.JSON(http.StatusConflict, gin.H{"error": errPeriodLocked.Error()})
+		return
+	}
+
+	now := time.Now()
+	reviewer := reviewerID.(uint)
+	leave.Status = status
+	leave.ReviewedBy = &reviewer
+	leave.ReviewedAt = &now
+	leave.ReviewNote = req.Note
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Only one of concurrent reviews may win, or the balance would be
+		// debited twice
+		result := tx.Model(&LeaveRequest{}).
+			Where("id = ? AND status = ?", leave.ID, "pending").
+			Select("status", "reviewed_by", "reviewed_at", "review_note").
+			Updates(&leave)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errLeaveNotPending
+		}
+		if status == "approved" {
+			return debitLeaveBalance(tx, leave)
+		}
+		return nil
+	})
+	if errors.Is(err, errInsufficientBalance) || errors.Is(err, errLeaveNotPending) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to review leave request"})
+		return
+	}
+
+	c.JSON(http.StatusOK, leave)
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Leave accrual frequencies
+const (
+	accrualNone      = "none"
+	accrualMonthly   = "monthly"
+	accrualPayPeriod = "pay_period"
+	accrualYearly    = "yearly"
+)
+
+// payPeriodStart is the first day of some pay period; the following periods
+// are PayPeriodDays long each.
+var payPeriodStart = "2024-01-01"
+
+var errInsufficientBalance = errors.New("Insufficient leave balance")
+
+// tracksBalance reports whether leave of this type is limited by a balance.
+func (t LeaveType) tracksBalance() bool {
+	return t.AccrualFrequency != "" && t.AccrualFrequency != accrualNone
+}
+
+func (t LeaveType) payPeriodDays() int {
+	if t.PayPeriodDays > 0 {
+		return t.PayPeriodDays
+	}
+	return 14
+}
+
+// periodStart returns the start of the accrual period containing day.
+func (t LeaveType) periodStart(day time.Time) time.Time {
+	loc := day.Location()
+	switch t.AccrualFrequency {
+	case accrualYearly:
+		return time.Date(day.Year(), time.January, 1, 0, 0, 0, 0, loc)
+	case accrualPayPeriod:
+		return payPeriodContaining(day, t.payPeriodDays())
+	default:
+		return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, loc)
+	}
+}
+
+// nextPeriod returns the start of the accrual period following the one starting at start.
+func (t LeaveType) nextPeriod(start time.Time) time.Time {
+	switch t.AccrualFrequency {
+	case accrualYearly:
+		return start.AddDate(1, 0, 0)
+	case accrualPayPeriod:
+		return start.AddDate(0, 0, t.payPeriodDays())
+	default:
+		return start.AddDate(0, 1, 0)
+	}
+}
+
+// payPeriodContaining returns the start of the pay period of the given length
+// containing day, counting from payPeriodStart.
+func payPeriodContaining(day time.Time, length int) time.Time {
+	anchor, _ := parseDate(payPeriodStart, day.Location())
+	elapsed := daysBetween(anchor, day)
+	periods := int(math.Floor(float64(elapsed) / float64(length)))
+	return anchor.AddDate(0, 0, periods*length)
+}
+
+// daysBetween counts calendar days from a to b, ignoring daylight saving shifts.
+func daysBetween(a, b time.Time) int {
+	ua := time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	ub := time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+	return int(ub.Sub(ua).Hours() / 24)
+}
+
+func roundDays(days float64) float64 {
+	return math.Round(days*100) / 100
+}
+
+// addLedgerEntry applies amount to balance and records it in the ledger.
+func addLedgerEntry(tx *gorm.DB, balance *LeaveBalance, kind string, amount float64, effective string, leaveRequestID *uint, note string) error {
+	balance.Balance = roundDays(balance.Balance + amount)
+	return tx.Create(&LeaveLedgerEntry{
+		UserID:         balance.UserID,
+		LeaveTypeID:    balance.LeaveTypeID,
+		Kind:           kind,
+		Amount:         amount,
+		BalanceAfter:   balance.Balance,
+		EffectiveDate:  Date(effective),
+		LeaveRequestID: leaveRequestID,
+		Note:           note,
+	}).Error
+}
+
+// accrueLeave brings a user's balance for a leave type up to date: every
+// accrual period that started on or before asOf is credited, and balances
+// above the carry-over cap are cut back at the start of each year.
+func accrueLeave(tx *gorm.DB, user User, leaveType LeaveType, asOf time.Time) (LeaveBalance, error) {
+	var balance LeaveBalance
+	err := tx.Where("user_id = ? AND leave_type_id = ?", user.ID, leaveType.ID).First(&balance).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		balance = LeaveBalance{UserID: user.ID, LeaveTypeID: leaveType.ID}
+	} else if err != nil {
+		return balance, err
+	}
+
+	if !leaveType.tracksBalance() {
+		return balance, nil
+	}
+
+	// Accrual starts with the period the user joined in
+	loc := userLocation(user)
+	var next time.Time
+	if balance.AccruedThrough == "" {
+		next = leaveType.periodStart(user.CreatedAt.In(loc))
+	} else {
+		last, _ := parseDate(string(balance.AccruedThrough), loc)
+		next = leaveType.nextPeriod(last)
+	}
+
+	for !next.After(asOf) {
+		if balance.AccruedThrough != "" && leaveType.CarryOverCap != nil {
+			last, _ := parseDate(string(balance.AccruedThrough), loc)
+			for year := last.Year() + 1; year <= next.Year(); year++ {
+				if balance.Balance <= *leaveType.CarryOverCap {
+					continue
+				}
+				yearStart := time.Date(year, time.January, 1, 0, 0, 0, 0, loc).Format(dateLayout)
+				note := fmt.Sprintf("Carry-over capped at %g days", *leaveType.CarryOverCap)
+				if err := addLedgerEntry(tx, &balance, "carry_over_reset", *leaveType.CarryOverCap-balance.Balance, yearStart, nil, note); err != nil {
+					return balance, err
+				}
+			}
+		}
+
+		if err := addLedgerEntry(tx, &balance, "accrual", leaveType.AccrualDays, next.Format(dateLayout), nil, ""); err != nil {
+			return balance, err
+		}
+		balance.AccruedThrough = Date(next.Format(dateLayout))
+		next = leaveType.nextPeriod(next)
+	}
+
+	return balance, tx.Omit(clause.Associations).Save(&balance).Error
+}
+
+// debitLeaveBalance takes approved leave out of the user's balance.
+func debitLeaveBalance(tx *gorm.DB, leave LeaveRequest) error {
+	if !leave.LeaveType.tracksBalance() {
+		return nil
+	}
+
+	today := time.Now().In(userLocation(leave.User))
+	balance, err := accrueLeave(tx, leave.User, leave.LeaveType, today)
+	if err != nil {
+		return err
+	}
+	if balance.Balance < leave.Days {
+		return errInsufficientBalance
+	}
+
+	note := fmt.Sprintf("Leave %s to %s", leave.StartDate, leave.EndDate)
+	if err := addLedgerEntry(tx, &balance, "usage", -leave.Days, today.Format(dateLayout), &leave.ID, note); err != nil {
+		return err
+	}
+	return tx.Omit(clause.Associations).Save(&balance).Error
+}
+
+// refundLeaveBalance returns cancelled leave to the user's balance.
+func refundLeaveBalance(tx *gorm.DB, leave LeaveRequest) error {
+	if !leave.LeaveType.tracksBalance() {
+		return nil
+	}
+
+	today := time.Now().In(userLocation(leave.User))
+	balance, err := accrueLeave(tx, leave.User, leave.LeaveType, today)
+	if err != nil {
+		return err
+	}
+
+	if err := addLedgerEntry(tx, &balance, "refund", leave.Days, today.Format(dateLayout), &leave.ID, "Leave cancelled"); err != nil {
+		return err
+	}
+	return tx.Omit(clause.Associations).Save(&balance).Error
+}
+
+func getMyLeaveBalance(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var user User
+	if err := db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	respondLeaveBalance(c, user)
+}
+
+func getUserLeaveBalance(c *gin.Context) {
+	var user User
+	if err := db.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	respondLeaveBalance(c, user)
+}
+
+// respondLeaveBalance accrues and reports the user's balance for every
+// balance-tracked leave type, along with the ledger behind each balance.
+func respondLeaveBalance(c *gin.Context, user User) {
+	var leaveTypes []LeaveType
+	if err := db.Order("id").Find(&leaveTypes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch leave types"})
+		return
+	}
+
+	today := time.Now().In(userLocation(user))
+	balances := []LeaveBalanceResponse{}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, leaveType := range leaveTypes {
+			if !leaveType.tracksBalance() {
+				continue
+			}
+
+			balance, err := accrueLeave(tx, user, leaveType, today)
+			if err != nil {
+				return err
+			}
+
+			var ledger []LeaveLedgerEntry
+			if err := tx.Where("user_id = ? AND leave_type_id = ?", user.ID, leaveType.ID).
+				Order("effective_date, id").
+				Find(&ledger).Error; err != nil {
+				return err
+			}
+
+			balances = append(balances, LeaveBalanceResponse{
+				LeaveType:      leaveType,
+				Balance:        balance.Balance,
+				AccruedThrough: balance.AccruedThrough,
+				Ledger:         ledger,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute leave balance"})
+		return
+	}
+
+	c.JSON(http.StatusOK, balances)
+}
+
+func updateLeaveType(c *gin.Context) {
+	var leaveType LeaveType
+	if err := db.First(&leaveType, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Leave type not found"})
+		return
+	}
+
+	var req UpdateLeaveTypeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	switch req.AccrualFrequency {
+	case accrualNone, accrualMonthly, accrualPayPeriod, accrualYearly:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid accrual_frequency. Must be 'none', 'monthly', 'pay_period' or 'yearly'"})
+		return
+	}
+	if req.CarryOverCap != nil && *req.CarryOverCap < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "carry_over_cap must not be negative"})
+		return
+	}
+
+	if req.Name != "" {
+		leaveType.Name = req.Name
+	}
+	if req.Paid != nil {
+		leaveType.Paid = *req.Paid
+	}
+	leaveType.AccrualFrequency = req.AccrualFrequency
+	leaveType.AccrualDays = req.AccrualDays
+	leaveType.PayPeriodDays = req.PayPeriodDays
+	leaveType.CarryOverCap = req.CarryOverCap
+
+	if err := db.Save(&leaveType).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update leave type"})
+		return
+	}
+
+	c.JSON(http.StatusOK, leaveType)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Mail is a plain text email.
+type Mail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers email. MAIL_DRIVER selects the implementation: smtp for
+// real delivery, or log and file for local development.
+type Mailer interface {
+	Send(mail Mail) error
+}
+
+var mailer Mailer = logMailer{}
+
+// mailFrom is the sender address of outgoing mail.
+var mailFrom = "no-reply@localhost"
+
+// appURL is where the frontend is served, for links in emails.
+var appURL = "http://localhost:3000"
+
+// newMailer returns the mailer for driver, configured from the environment.
+func newMailer(driver string) (Mailer, error) {
+	switch driver {
+	case "log":
+		return logMailer{}, nil
+	case "file":
+		dir := getEnv("MAIL_DIR", "mail")
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+		return fileMailer{dir: dir}, nil
+	case "smtp":
+		host := getEnv("SMTP_HOST", "")
+		if host == "" {
+			return nil, errors.New("SMTP_HOST is required for the smtp mail driver")
+		}
+		return smtpMailer{
+			addr:     net.JoinHostPort(host, strconv.Itoa(getEnvInt("SMTP_PORT", 587))),
+			host:     host,
+			username: getEnv("SMTP_USERNAME", ""),
+			password: getEnv("SMTP_PASSWORD", ""),
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown MAIL_DRIVER %q, expected smtp, log or file", driver)
+}
+
+// formatMail renders mail as an RFC 5322 message.
+func formatMail(mail Mail) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + mailFrom + "\r\n")
+	b.WriteString("To: " + mail.To + "\r\n")
+	b.WriteString("Subject: " + mail.Subject + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(mail.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// smtpMailer sends mail through an SMTP server, authenticating when a
+// username is set. net/smtp upgrades to TLS when the server offers it.
+type smtpMailer struct {
+	addr     string
+	host     string
+	username string
+	password string
+}
+
+func (m smtpMailer) Send(mail Mail) error {
+	var auth smtp.Auth
+	if m.username != "" {
+		auth = smtp.PlainAuth("", m.username, m.password, m.host)
+	}
+	return smtp.SendMail(m.addr, auth, mailFrom, []string{mail.To}, formatMail(mail))
+}
+
+// logMailer writes mail to the server log instead of sending it.
+type logMailer struct{}
+
+func (logMailer) Send(mail Mail) error {
+	log.Printf("Mail to %s: %s\n%s", mail.To, mail.Subject, mail.Body)
+	return nil
+}
+
+// fileMailer saves each mail as an .eml file in dir instead of sending it.
+type fileMailer struct {
+	dir string
+}
+
+func (m fileMailer) Send(mail Mail) error {
+	name := fmt.Sprintf("%s_%s.eml", time.Now().Format("20060102T150405.000000000"), strings.ReplaceAll(mail.To, "@", "_at_"))
+	return os.WriteFile(filepath.Join(m.dir, filepath.Base(name)), formatMail(mail), 0o644)
+}
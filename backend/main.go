@@ -4,6 +4,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	_ "time/tzdata" // IANA timezones for hosts without a zoneinfo database
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -20,9 +21,14 @@ func main() {
 		log.Println("No .env file found")
 	}
 
+	loadConfig()
+
 	// Initialize database
 	initDB()
 
+	// Start background jobs
+	startScheduler()
+
 	// Setup router
 	r := gin.Default()
 
@@ -55,11 +61,17 @@ func initDB() {
 	}
 
 	// Auto migrate schemas
-	err = db.AutoMigrate(&User{}, &Attendance{})
+	err = db.AutoMigrate(&User{}, &Attendance{}, &AttendanceSession{}, &WorkSchedule{}, &LeaveType{}, &LeaveRequest{}, &LeaveBalance{}, &LeaveLedgerEntry{}, &Holiday{}, &Notification{}, &CorrectionRequest{}, &AttendanceAuditLog{}, &Timesheet{}, &Alert{}, &UserSession{}, &RefreshToken{}, &PasswordResetToken{}, &PasswordHistory{}, &RecoveryCode{}, &MFAChallenge{}, &SecuritySettings{})
 	if err != nil {
 		log.Fatal("Failed to migrate database:", err)
 	}
 
+	migrateLegacyData()
+
+	if err := seedLeaveTypes(); err != nil {
+		log.Fatal("Failed to seed leave types:", err)
+	}
+
 	log.Println("Database initialized successfully")
 }
 
@@ -70,7 +82,12 @@ func setupRoutes(r *gin.Engine) {
 		auth := api.Group("/auth")
 		{
 			auth.POST("/login", login)
+			auth.POST("/login/verify", verifyLogin)
 			auth.POST("/register", register)
+			auth.POST("/refresh", refreshSession)
+			auth.POST("/logout", logout)
+			auth.POST("/forgot-password", forgotPassword)
+			auth.POST("/reset-password", resetPassword)
 		}
 
 		// Protected routes
@@ -79,13 +96,55 @@ func setupRoutes(r *gin.Engine) {
 		{
 			protected.GET("/profile", getProfile)
 			protected.PUT("/profile", updateProfile)
+			protected.PUT("/profile/password", changePassword)
+			protected.GET("/profile/2fa", getTwoFactorStatus)
+			protected.POST("/profile/2fa/setup", setupTwoFactor)
+			protected.POST("/profile/2fa/enable", enableTwoFactor)
+			protected.POST("/profile/2fa/disable", disableTwoFactor)
+			protected.POST("/profile/2fa/recovery-codes", regenerateRecoveryCodes)
+			protected.GET("/profile/leave-balance", getMyLeaveBalance)
 			
 			// Attendance routes
 			protected.POST("/attendance/checkin", checkIn)
 			protected.POST("/attendance/checkout", checkOut)
+			protected.POST("/attendance/break/start", startBreak)
+			protected.POST("/attendance/break/end", endBreak)
 			protected.GET("/attendance", getAttendanceHistory)
 			protected.GET("/attendance/today", getTodayAttendance)
 			protected.GET("/attendance/stats", getAttendanceStats)
+			protected.GET("/attendance/report", getMyAttendanceReport)
+			protected.GET("/attendance/schedule", getMySchedule)
+			protected.GET("/attendance/corrections", getMyCorrectionRequests)
+			protected.POST("/attendance/corrections", submitCorrectionRequest)
+			protected.POST("/attendance/corrections/:id/cancel", cancelCorrectionRequest)
+
+			// Leave routes
+			protected.GET("/leave/types", getLeaveTypes)
+			protected.GET("/leave", getMyLeaveRequests)
+			protected.POST("/leave", submitLeaveRequest)
+			protected.POST("/leave/:id/cancel", cancelLeaveRequest)
+			protected.GET("/holidays", getHolidays)
+
+			// Timesheet routes
+			protected.GET("/timesheets", getMyTimesheets)
+			protected.GET("/timesheets/current", getCurrentTimesheet)
+			protected.GET("/timesheets/:id", getMyTimesheet)
+			protected.POST("/timesheets/:id/submit", submitTimesheet)
+
+			// Notification routes
+			protected.GET("/notifications", getNotifications)
+			protected.POST("/notifications/read-all", markAllNotificationsRead)
+			protected.POST("/notifications/:id/read", markNotificationRead)
+		}
+
+		// Manager routes, also open to admins
+		manager := api.Group("/manager")
+		manager.Use(authMiddleware(), managerMiddleware())
+		{
+			manager.GET("/timesheets", getTeamTimesheets)
+			manager.GET("/timesheets/:id", getTeamTimesheet)
+			manager.POST("/timesheets/:id/approve", approveTimesheet)
+			manager.POST("/timesheets/:id/reject", rejectTimesheet)
 		}
 
 		// Admin routes
@@ -94,8 +153,82 @@ func setupRoutes(r *gin.Engine) {
 		{
 			admin.GET("/users", getAllUsers)
 			admin.GET("/attendance", getAllAttendance)
+			admin.POST("/attendance", createAttendance)
+			admin.GET("/attendance/export", exportAttendance)
+			admin.PUT("/attendance/:id", updateAttendance)
+			admin.DELETE("/attendance/:id", deleteAttendance)
+			admin.GET("/attendance/:id/audit", getAttendanceAudit)
+			admin.GET("/overtime", getOvertime)
+			admin.POST("/overtime/:id/approve", approveOvertime)
+			admin.POST("/overtime/:id/reject", rejectOvertime)
+			admin.POST("/timesheets/:id/reopen", reopenTimesheet)
+			admin.GET("/payroll/export", exportPayroll)
+			admin.GET("/analytics/company", getCompanyAnalytics)
+			admin.GET("/analytics/departments", getDepartmentAnalytics)
+			admin.GET("/dashboard/heatmap", getDashboardHeatmap)
+			admin.GET("/dashboard/histogram", getDashboardHistogram)
+			admin.GET("/dashboard/trends", getDashboardTrends)
+			admin.GET("/alerts", getAlerts)
+			admin.POST("/alerts/run", runAlerts)
+			admin.POST("/alerts/:id/acknowledge", acknowledgeAlert)
+			admin.POST("/attendance/absences/backfill", backfillAbsences)
+			admin.GET("/attendance/corrections", getAllCorrectionRequests)
+			admin.POST("/attendance/corrections/:id/approve", approveCorrectionRequest)
+			admin.POST("/attendance/corrections/:id/reject", rejectCorrectionRequest)
 			admin.PUT("/users/:id", updateUser)
 			admin.DELETE("/users/:id", deleteUser)
+			admin.GET("/users/:id/sessions", getUserSessions)
+			admin.POST("/users/:id/sessions/revoke", revokeUserSessions)
+			admin.POST("/users/:id/2fa/reset", resetUserTwoFactor)
+			admin.GET("/users/:id/schedule", getUserSchedule)
+			admin.GET("/users/:id/stats", getUserAttendanceStats)
+			admin.GET("/users/:id/report", getUserAttendanceReport)
+
+			// Security settings
+			admin.GET("/settings/security", getSecuritySettings)
+			admin.PUT("/settings/security", updateSecuritySettings)
+
+			// Work schedules
+			admin.GET("/schedules", getSchedules)
+			admin.POST("/schedules", createSchedule)
+			admin.PUT("/schedules/:id", updateSchedule)
+			admin.DELETE("/schedules/:id", deleteSchedule)
+
+			// Leave approval
+			admin.GET("/leave", getAllLeaveRequests)
+			admin.POST("/leave/:id/approve", approveLeaveRequest)
+			admin.POST("/leave/:id/reject", rejectLeaveRequest)
+			admin.PUT("/leave/types/:id", updateLeaveType)
+			admin.GET("/users/:id/leave-balance", getUserLeaveBalance)
+
+			// Holiday calendar
+			admin.GET("/holidays", getAllHolidays)
+			admin.POST("/holidays", createHoliday)
+			admin.POST("/holidays/import", importHolidays)
+			admin.PUT("/holidays/:id", updateHoliday)
+			admin.DELETE("/holidays/:id", deleteHoliday)
 		}
 	}
-}
\ No newline at end of file
+}
+
+// migrateLegacyData upgrades rows written by earlier versions of the schema.
+func migrateLegacyData() {
+	// Attendance dates used to be stored as UTC timestamps; keep only the calendar date
+	if err := db.Exec("UPDATE attendances SET date = substr(date, 1, 10) WHERE length(date) > 10").Error; err != nil {
+		log.Fatal("Failed to migrate attendance dates:", err)
+	}
+
+	// Records from before sessions existed get a single work session. Their
+	// worked minutes are the whole shift, set first while the missing session
+	// still tells them apart from records summarized from sessions
+	if err := db.Exec(`UPDATE attendances SET worked_minutes = CAST((julianday(check_out) - julianday(check_in)) * 1440 AS INTEGER)
+		WHERE check_in IS NOT NULL AND check_out IS NOT NULL
+		AND NOT EXISTS (SELECT 1 FROM attendance_sessions s WHERE s.attendance_id = attendances.id)`).Error; err != nil {
+		log.Fatal("Failed to backfill worked minutes:", err)
+	}
+	if err := db.Exec(`INSERT INTO attendance_sessions (attendance_id, type, started_at, ended_at, created_at, updated_at)
+		SELECT id, 'work', check_in, check_out, created_at, updated_at FROM attendances a
+		WHERE check_in IS NOT NULL AND NOT EXISTS (SELECT 1 FROM attendance_sessions s WHERE s.attendance_id = a.id)`).Error; err != nil {
+		log.Fatal("Failed to migrate attendance sessions:", err)
+	}
+}
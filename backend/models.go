@@ -11,9 +11,14 @@ type User struct {
 	Email     string         `json:"email" gorm:"unique;not null"`
 	Name      string         `json:"name" gorm:"not null"`
 	Password  string         `json:"-" gorm:"not null"`
-	Role      string         `json:"role" gorm:"default:employee"` // employee, admin
+	Role      string         `json:"role" gorm:"default:employee"` // employee, manager, admin
 	Position  string         `json:"position"`
 	Department string        `json:"department"`
+	Timezone  string         `json:"timezone"` // IANA name, empty uses the organization default
+	TokenVersion uint        `json:"-" gorm:"not null;default:0"` // bumped to invalidate issued access tokens
+	TwoFactorEnabled bool    `json:"two_factor_enabled" gorm:"not null;default:false"`
+	TOTPSecret   string      `json:"-"` // base32; set on enrollment, used once TwoFactorEnabled
+	TOTPLastStep int64       `json:"-"` // time step of the last accepted code, which cannot be used again
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
@@ -23,16 +28,315 @@ type Attendance struct {
 	ID        uint           `json:"id" gorm:"primaryKey"`
 	UserID    uint           `json:"user_id" gorm:"not null"`
 	User      User           `json:"user" gorm:"foreignKey:UserID"`
-	Date      time.Time      `json:"date" gorm:"type:date;not null"`
+	Date      Date           `json:"date" gorm:"type:date;not null;index"` // YYYY-MM-DD in the user's timezone
 	CheckIn   *time.Time     `json:"check_in"`
 	CheckOut  *time.Time     `json:"check_out"`
 	Notes     string         `json:"notes"`
 	Status    string         `json:"status" gorm:"default:present"` // present, absent, late, half_day
+	WorkedMinutes int        `json:"worked_minutes"` // total of completed work sessions
+	BreakMinutes  int        `json:"break_minutes"`  // total of completed breaks
+	HolidayWork   bool       `json:"holiday_work"`   // checked in on a holiday
+	AutoClosed    bool       `json:"auto_closed"`    // checked out by the auto-checkout job
+	NeedsReview   bool       `json:"needs_review"`   // auto-closed without crediting the last session
+	ScheduledMinutes   int        `json:"scheduled_minutes"`   // expected work time under the schedule
+	OvertimeMinutes    int        `json:"overtime_minutes"`    // worked beyond the daily or weekly threshold
+	OvertimeMultiplier float64    `json:"overtime_multiplier"` // pay rate applied to the overtime
+	OvertimeStatus     string     `json:"overtime_status"`     // pending, approved, rejected; empty without overtime
+	OvertimeReviewedBy *uint      `json:"overtime_reviewed_by"`
+	OvertimeReviewedAt *time.Time `json:"overtime_reviewed_at"`
+	Sessions  []AttendanceSession `json:"sessions,omitempty" gorm:"foreignKey:AttendanceID"`
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
 }
 
+// AttendanceSession is one continuous span of work or break within a day's
+// attendance. CheckIn and CheckOut on Attendance are derived from these.
+type AttendanceSession struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	AttendanceID uint       `json:"attendance_id" gorm:"not null;index"`
+	Type         string     `json:"type" gorm:"not null;default:work"` // work, break
+	StartedAt    time.Time  `json:"started_at" gorm:"not null"`
+	EndedAt      *time.Time `json:"ended_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// WorkSchedule defines working hours for a user or department. Users without
+// a personal or department schedule fall back to the configured default.
+type WorkSchedule struct {
+	ID                 uint           `json:"id" gorm:"primaryKey"`
+	Name               string         `json:"name"`
+	UserID             *uint          `json:"user_id" gorm:"index"`
+	Department         string         `json:"department" gorm:"index"`
+	StartTime          string         `json:"start_time" gorm:"not null"` // HH:MM
+	EndTime            string         `json:"end_time" gorm:"not null"`   // HH:MM
+	GracePeriodMinutes int            `json:"grace_period_minutes"`
+	HalfDayMinutes     int            `json:"half_day_minutes"`             // worked minutes below this count as a half day
+	WorkingDays        string         `json:"working_days" gorm:"not null"` // comma separated weekdays, 0 = Sunday
+	AutoCheckoutPolicy string         `json:"auto_checkout_policy"`         // scheduled_end, max_hours, flag, off; empty uses the default
+	AutoCheckoutHours  int            `json:"auto_checkout_hours"`          // shift length limit for max_hours
+	CreatedAt          time.Time      `json:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at"`
+	DeletedAt          gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// LeaveType is a category of planned absence such as vacation or sick leave.
+type LeaveType struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Code      string    `json:"code" gorm:"unique;not null"` // vacation, sick, unpaid
+	Name      string    `json:"name" gorm:"not null"`
+	Paid      bool      `json:"paid"`
+
+	// Accrual policy; leave types that do not accrue are not limited by a balance
+	AccrualFrequency string   `json:"accrual_frequency"` // none, monthly, pay_period, yearly
+	AccrualDays      float64  `json:"accrual_days"`      // days credited at the start of each period
+	PayPeriodDays    int      `json:"pay_period_days"`   // period length for pay_period accrual
+	CarryOverCap     *float64 `json:"carry_over_cap"`    // days kept at the start of a year, nil keeps everything
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// LeaveRequest asks for leave over an inclusive range of calendar dates.
+type LeaveRequest struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	UserID      uint       `json:"user_id" gorm:"not null;index"`
+	User        User       `json:"user" gorm:"foreignKey:UserID"`
+	LeaveTypeID uint       `json:"leave_type_id" gorm:"not null"`
+	LeaveType   LeaveType  `json:"leave_type" gorm:"foreignKey:LeaveTypeID"`
+	StartDate   Date       `json:"start_date" gorm:"type:date;not null;index"`
+	EndDate     Date       `json:"end_date" gorm:"type:date;not null;index"`
+	HalfDay     bool       `json:"half_day"`
+	Days        float64    `json:"days"` // working days covered
+	Reason      string     `json:"reason"`
+	Status      string     `json:"status" gorm:"default:pending;index"` // pending, approved, rejected, cancelled
+	ReviewedBy  *uint      `json:"reviewed_by"`
+	ReviewedAt  *time.Time `json:"reviewed_at"`
+	ReviewNote  string     `json:"review_note"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// LeaveBalance is a user's running balance of days for a leave type.
+type LeaveBalance struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	UserID         uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_leave_balance_user_type"`
+	LeaveTypeID    uint      `json:"leave_type_id" gorm:"not null;uniqueIndex:idx_leave_balance_user_type"`
+	Balance        float64   `json:"balance"`
+	AccruedThrough Date      `json:"accrued_through" gorm:"type:date"` // start of the last credited period
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// LeaveLedgerEntry records one change to a leave balance.
+type LeaveLedgerEntry struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	UserID         uint      `json:"user_id" gorm:"not null;index"`
+	LeaveTypeID    uint      `json:"leave_type_id" gorm:"not null;index"`
+	Kind           string    `json:"kind" gorm:"not null"` // accrual, usage, refund, carry_over_reset
+	Amount         float64   `json:"amount"`               // positive credits, negative debits
+	BalanceAfter   float64   `json:"balance_after"`
+	EffectiveDate  Date      `json:"effective_date" gorm:"type:date;not null"`
+	LeaveRequestID *uint     `json:"leave_request_id"`
+	Note           string    `json:"note"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// Holiday is a public holiday. Holidays without a department apply to
+// everyone; department holidays only to users in that department.
+type Holiday struct {
+	ID         uint           `json:"id" gorm:"primaryKey"`
+	Date       Date           `json:"date" gorm:"type:date;not null;index"`
+	Name       string         `json:"name" gorm:"not null"`
+	Department string         `json:"department" gorm:"index"`
+	UID        string         `json:"uid" gorm:"index"` // iCalendar UID of imported holidays
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// CorrectionRequest is an employee's proposed change to the attendance record
+// of a day. Proposed values left empty keep the current ones.
+type CorrectionRequest struct {
+	ID               uint                `json:"id" gorm:"primaryKey"`
+	UserID           uint                `json:"user_id" gorm:"not null;index"`
+	User             User                `json:"user" gorm:"foreignKey:UserID"`
+	AttendanceID     *uint               `json:"attendance_id" gorm:"index"` // set on approval if the day had no record
+	Date             Date                `json:"date" gorm:"type:date;not null;index"`
+	ProposedCheckIn  *time.Time          `json:"proposed_check_in"`
+	ProposedCheckOut *time.Time          `json:"proposed_check_out"`
+	ProposedStatus   string              `json:"proposed_status"`
+	ProposedNotes    *string             `json:"proposed_notes"`
+	Reason           string              `json:"reason" gorm:"not null"`
+	Status           string              `json:"status" gorm:"default:pending;index"` // pending, approved, rejected, cancelled
+	ReviewedBy       *uint               `json:"reviewed_by"`
+	ReviewedAt       *time.Time          `json:"reviewed_at"`
+	ReviewNote       string              `json:"review_note"`
+	Before           *AttendanceSnapshot `json:"before" gorm:"type:text"` // record as it was before approval
+	After            *AttendanceSnapshot `json:"after" gorm:"type:text"`  // record as approved
+	CreatedAt        time.Time           `json:"created_at"`
+	UpdatedAt        time.Time           `json:"updated_at"`
+}
+
+// AttendanceSnapshot is a copy of the editable values of an attendance
+// record, stored as JSON for auditing changes.
+type AttendanceSnapshot struct {
+	Date          Date       `json:"date"`
+	CheckIn       *time.Time `json:"check_in"`
+	CheckOut      *time.Time `json:"check_out"`
+	Status        string     `json:"status"`
+	Notes         string     `json:"notes"`
+	WorkedMinutes int        `json:"worked_minutes"`
+	BreakMinutes  int        `json:"break_minutes"`
+}
+
+// AttendanceAuditLog records a change to an attendance record and who made it.
+type AttendanceAuditLog struct {
+	ID           uint                `json:"id" gorm:"primaryKey"`
+	AttendanceID uint                `json:"attendance_id" gorm:"not null;index"`
+	ActorID      *uint               `json:"actor_id"` // nil for automatic changes
+	Actor        *User               `json:"actor,omitempty" gorm:"foreignKey:ActorID"`
+	Action       string              `json:"action" gorm:"not null"` // create, update, delete, correction, auto_checkout
+	Reason       string              `json:"reason"`
+	Before       *AttendanceSnapshot `json:"before" gorm:"type:text"`
+	After        *AttendanceSnapshot `json:"after" gorm:"type:text"`
+	CreatedAt    time.Time           `json:"created_at"`
+}
+
+// Timesheet collects a user's attendance, leave and overtime for a week or
+// month for sign-off. Once approved, attendance in the period is locked until
+// an admin reopens it.
+type Timesheet struct {
+	ID               uint       `json:"id" gorm:"primaryKey"`
+	UserID           uint       `json:"user_id" gorm:"not null;uniqueIndex:idx_timesheet_user_start"`
+	User             User       `json:"user" gorm:"foreignKey:UserID"`
+	Period           string     `json:"period" gorm:"not null"` // weekly, monthly
+	StartDate        Date       `json:"start_date" gorm:"type:date;not null;uniqueIndex:idx_timesheet_user_start"`
+	EndDate          Date       `json:"end_date" gorm:"type:date;not null;index"`
+	Status           string     `json:"status" gorm:"default:draft;index"` // draft, submitted, approved, rejected
+	WorkedMinutes    int        `json:"worked_minutes"`
+	ScheduledMinutes int        `json:"scheduled_minutes"`
+	OvertimeMinutes  int        `json:"overtime_minutes"` // approved overtime only
+	LeaveDays        float64    `json:"leave_days"`
+	AbsentDays       int        `json:"absent_days"`
+	SubmittedAt      *time.Time `json:"submitted_at"`
+	ReviewedBy       *uint      `json:"reviewed_by"`
+	ReviewedAt       *time.Time `json:"reviewed_at"`
+	ReviewNote       string     `json:"review_note"`
+	ReopenedBy       *uint      `json:"reopened_by"`
+	ReopenedAt       *time.Time `json:"reopened_at"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+// Notification is a message for a user, such as a notice that their shift
+// was checked out automatically.
+type Notification struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	UserID       uint       `json:"user_id" gorm:"not null;index"`
+	Type         string     `json:"type" gorm:"not null"` // auto_checkout, correction_reviewed, overtime_reviewed, timesheet_reviewed
+	Title        string     `json:"title"`
+	Message      string     `json:"message"`
+	AttendanceID *uint      `json:"attendance_id"`
+	ReadAt       *time.Time `json:"read_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// Alert flags an attendance pattern found by the alert rules, such as
+// frequent lateness, for an admin to follow up.
+type Alert struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	UserID         uint       `json:"user_id" gorm:"not null;index"`
+	User           User       `json:"user" gorm:"foreignKey:UserID"`
+	Rule           string     `json:"rule" gorm:"not null;index"` // late_frequency, monday_absences, friday_absences, check_in_drift
+	Fingerprint    string     `json:"-" gorm:"uniqueIndex;not null"` // rule, user and window, so reruns do not raise it twice
+	Message        string     `json:"message"`
+	WindowStart    Date       `json:"window_start" gorm:"type:date"`
+	WindowEnd      Date       `json:"window_end" gorm:"type:date"` // events up to here are covered by this alert
+	Occurrences    int        `json:"occurrences"`
+	Status         string     `json:"status" gorm:"default:open;index"` // open, acknowledged
+	AcknowledgedBy *uint      `json:"acknowledged_by"`
+	AcknowledgedAt *time.Time `json:"acknowledged_at"`
+	AcknowledgeNote string    `json:"acknowledge_note"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// UserSession is a login on one device. Its refresh tokens rotate on every
+// use; revoking the session also ends the access tokens issued for it.
+type UserSession struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	UserID     uint       `json:"user_id" gorm:"not null;index"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	LastUsedAt time.Time  `json:"last_used_at"`
+	ExpiresAt  time.Time  `json:"expires_at"` // moves forward with each refresh
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// RefreshToken is one token in a session's rotation chain. Only a hash of
+// the token is stored. A token that was already used being presented again
+// means it leaked, so the whole session is revoked.
+type RefreshToken struct {
+	ID        uint       `gorm:"primaryKey"`
+	SessionID uint       `gorm:"not null;index"`
+	TokenHash string     `gorm:"uniqueIndex;not null"` // hex SHA-256
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// PasswordResetToken lets a user who forgot their password set a new one.
+// Only a hash of the token is stored, and it works once, before ExpiresAt.
+type PasswordResetToken struct {
+	ID        uint       `gorm:"primaryKey"`
+	UserID    uint       `gorm:"not null;index"`
+	TokenHash string     `gorm:"uniqueIndex;not null"` // hex SHA-256
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// PasswordHistory keeps the hashes of a user's previous passwords, so that
+// the password policy can reject reusing them.
+type PasswordHistory struct {
+	ID           uint   `gorm:"primaryKey"`
+	UserID       uint   `gorm:"not null;index"`
+	PasswordHash string `gorm:"not null"`
+	CreatedAt    time.Time
+}
+
+// RecoveryCode signs a user in once in place of a TOTP code, for when their
+// authenticator is lost. Only a hash of the code is stored.
+type RecoveryCode struct {
+	ID        uint       `gorm:"primaryKey"`
+	UserID    uint       `gorm:"not null;index"`
+	CodeHash  string     `gorm:"not null;index"` // hex SHA-256 of the normalized code
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// MFAChallenge is a login that passed the password check and waits for the
+// second factor. The client holds the token; only its hash is stored.
+type MFAChallenge struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"not null;index"`
+	TokenHash string    `gorm:"uniqueIndex;not null"` // hex SHA-256
+	Attempts  int       `gorm:"not null;default:0"`
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+// SecuritySettings are organization-wide security options admins can
+// change at runtime. There is a single row.
+type SecuritySettings struct {
+	ID                    uint      `json:"-" gorm:"primaryKey"`
+	RequireAdminTwoFactor bool      `json:"require_admin_two_factor"`
+	UpdatedAt             time.Time `json:"updated_at"`
+}
+
 // Request/Response DTOs
 type LoginRequest struct {
 	Email    string `json:"email" binding:"required,email"`
@@ -42,14 +346,70 @@ type LoginRequest struct {
 type RegisterRequest struct {
 	Email      string `json:"email" binding:"required,email"`
 	Name       string `json:"name" binding:"required"`
-	Password   string `json:"password" binding:"required,min=6"`
+	Password   string `json:"password" binding:"required"` // checked against the password policy
 	Position   string `json:"position"`
 	Department string `json:"department"`
+	Timezone   string `json:"timezone"`
 }
 
 type AuthResponse struct {
-	Token string `json:"token"`
-	User  User   `json:"user"`
+	Token        string    `json:"token"` // short-lived access token
+	ExpiresAt    time.Time `json:"expires_at"`
+	RefreshToken string    `json:"refresh_token"`
+	User         User      `json:"user"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// MFAChallengeResponse answers a login of a user with two-factor
+// authentication; the code goes to /auth/login/verify with the token.
+type MFAChallengeResponse struct {
+	MFARequired bool      `json:"mfa_required"`
+	MFAToken    string    `json:"mfa_token"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+type LoginVerifyRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"` // TOTP or recovery code
+}
+
+type TwoFactorSetupRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+type TwoFactorSetupResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type TwoFactorDisableRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"` // TOTP or recovery code
+}
+
+type SecuritySettingsRequest struct {
+	RequireAdminTwoFactor *bool `json:"require_admin_two_factor" binding:"required"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
 }
 
 type CheckInRequest struct {
@@ -61,11 +421,68 @@ type CheckOutRequest struct {
 }
 
 type AttendanceStats struct {
-	TotalDays     int     `json:"total_days"`
+	TotalDays     int     `json:"total_days"` // working days in the period
+	AttendedDays  int     `json:"attended_days"`
 	PresentDays   int     `json:"present_days"`
 	AbsentDays    int     `json:"absent_days"`
 	LateDays      int     `json:"late_days"`
+	HalfDayDays   int     `json:"half_day_days"`
+	LeaveDays     float64 `json:"leave_days"`
+	HolidayDays   int     `json:"holiday_days"`
+	HolidayWorkDays int   `json:"holiday_work_days"`
+	TotalWorkedHours float64 `json:"total_worked_hours"`
+	OvertimeHours float64 `json:"overtime_hours"` // approved overtime only
+	AverageCheckIn  *string `json:"average_check_in"`  // HH:MM local time
+	AverageCheckOut *string `json:"average_check_out"` // HH:MM local time
+	CurrentOnTimeStreak int `json:"current_on_time_streak"` // working days attended on time
+	LongestOnTimeStreak int `json:"longest_on_time_streak"`
+	AttendanceRate float64 `json:"attendance_rate"`
+}
+
+// AnalyticsRow aggregates attendance for a period, company-wide or for one
+// department.
+type AnalyticsRow struct {
+	Period         string   `json:"period"` // first day of the day, week or month
+	Department     string   `json:"department,omitempty"`
+	Employees      int      `json:"employees"`
+	Records        int      `json:"records"`
+	AttendedDays   int      `json:"attended_days"`
+	LateDays       int      `json:"late_days"`
+	HalfDayDays    int      `json:"half_day_days"`
+	AbsentDays     int      `json:"absent_days"`
+	AttendanceRate float64  `json:"attendance_rate"`
+	LateRate       float64  `json:"late_rate"` // share of attended days
+	AverageCheckIn *string  `json:"average_check_in"` // HH:MM local time
+}
+
+// HeatmapDay counts the attendance records of a day by status.
+type HeatmapDay struct {
+	Date    string `json:"date"`
+	Present int    `json:"present"`
+	Late    int    `json:"late"`
+	HalfDay int    `json:"half_day"`
+	Absent  int    `json:"absent"`
+	Total   int    `json:"total"`
+}
+
+// HistogramBucket counts the check-ins made in a span of the day.
+type HistogramBucket struct {
+	Start string `json:"start"` // HH:MM local time
+	Count int    `json:"count"`
+}
+
+// TrendWeek holds the attendance figures of a week, Monday to Sunday.
+type TrendWeek struct {
+	Week           string  `json:"week"` // the Monday
+	Records        int     `json:"records"`
+	AttendedDays   int     `json:"attended_days"`
+	LateDays       int     `json:"late_days"`
+	AbsentDays     int     `json:"absent_days"`
+	WorkedHours    float64 `json:"worked_hours"`
 	AttendanceRate float64 `json:"attendance_rate"`
+	LateRate       float64 `json:"late_rate"`
+	AttendanceRateChange *float64 `json:"attendance_rate_change"` // from the week before, nil for the first week
+	LateRateChange       *float64 `json:"late_rate_change"`
 }
 
 type UpdateUserRequest struct {
@@ -73,10 +490,98 @@ type UpdateUserRequest struct {
 	Position   string `json:"position"`
 	Department string `json:"department"`
 	Role       string `json:"role"`
+	Timezone   string `json:"timezone"`
 }
 
 type UpdateProfileRequest struct {
 	Name       string `json:"name"`
 	Position   string `json:"position"`
 	Department string `json:"department"`
-}
\ No newline at end of file
+	Timezone   string `json:"timezone"`
+}
+
+type WorkScheduleRequest struct {
+	Name               string `json:"name"`
+	UserID             *uint  `json:"user_id"`
+	Department         string `json:"department"`
+	StartTime          string `json:"start_time" binding:"required"`
+	EndTime            string `json:"end_time" binding:"required"`
+	GracePeriodMinutes *int   `json:"grace_period_minutes" binding:"omitempty,min=0"` // nil uses the default schedule's
+	HalfDayMinutes     *int   `json:"half_day_minutes" binding:"omitempty,min=0"`     // nil uses the default schedule's
+	WorkingDays        string `json:"working_days"`
+	AutoCheckoutPolicy string `json:"auto_checkout_policy"`
+	AutoCheckoutHours  int    `json:"auto_checkout_hours" binding:"min=0"`
+}
+
+type CreateLeaveRequest struct {
+	LeaveTypeID uint   `json:"leave_type_id" binding:"required"`
+	StartDate   string `json:"start_date" binding:"required"`
+	EndDate     string `json:"end_date"` // defaults to start_date
+	HalfDay     bool   `json:"half_day"`
+	Reason      string `json:"reason"`
+}
+
+type ReviewRequest struct {
+	Note string `json:"note"`
+}
+
+type CreateCorrectionRequest struct {
+	Date     string     `json:"date" binding:"required"`
+	CheckIn  *time.Time `json:"check_in"`
+	CheckOut *time.Time `json:"check_out"`
+	Status   string     `json:"status"`
+	Notes    *string    `json:"notes"`
+	Reason   string     `json:"reason" binding:"required"`
+}
+
+type CreateAttendanceRequest struct {
+	UserID   uint       `json:"user_id" binding:"required"`
+	Date     string     `json:"date" binding:"required"`
+	CheckIn  *time.Time `json:"check_in"` // omit to record an absence
+	CheckOut *time.Time `json:"check_out"`
+	Notes    string     `json:"notes"`
+	Reason   string     `json:"reason"`
+}
+
+type UpdateAttendanceRequest struct {
+	Date     string     `json:"date"`
+	CheckIn  *time.Time `json:"check_in"`
+	CheckOut *time.Time `json:"check_out"`
+	Notes    *string    `json:"notes"`
+	Reason   string     `json:"reason"`
+}
+
+// TimesheetDetail is a timesheet with the records it covers.
+type TimesheetDetail struct {
+	Timesheet
+	Attendance []Attendance   `json:"attendance"`
+	Leave      []LeaveRequest `json:"leave"`
+}
+
+type UpdateLeaveTypeRequest struct {
+	Name             string   `json:"name"`
+	Paid             *bool    `json:"paid"`
+	AccrualFrequency string   `json:"accrual_frequency" binding:"required"`
+	AccrualDays      float64  `json:"accrual_days" binding:"min=0"`
+	PayPeriodDays    int      `json:"pay_period_days" binding:"min=0"`
+	CarryOverCap     *float64 `json:"carry_over_cap"`
+}
+
+type LeaveBalanceResponse struct {
+	LeaveType      LeaveType          `json:"leave_type"`
+	Balance        float64            `json:"balance"`
+	AccruedThrough Date               `json:"accrued_through"`
+	Ledger         []LeaveLedgerEntry `json:"ledger"`
+}
+
+type HolidayRequest struct {
+	Date       string `json:"date" binding:"required"`
+	Name       string `json:"name" binding:"required"`
+	Department string `json:"department"`
+}
+
+type BackfillAbsencesRequest struct {
+	StartDate string `json:"start_date" binding:"required"`
+	EndDate   string `json:"end_date" binding:"required"`
+	UserID    *uint  `json:"user_id"`
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// notify queues a notification for a user.
+func notify(tx *gorm.DB, userID uint, notificationType, title, message string, attendanceID *uint) error {
+	return tx.Create(&Notification{
+		UserID:       userID,
+		Type:         notificationType,
+		Title:        title,
+		Message:      message,
+		AttendanceID: attendanceID,
+	}).Error
+}
+
+func getNotifications(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	page, limit := parsePagination(c)
+	offset := (page - 1) * limit
+
+	query := db.Model(&Notification{}).Where("user_id = ?", userID)
+	if c.Query("unread") == "true" {
+		query = query.Where("read_at IS NULL")
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var notifications []Notification
+	if err := query.Order("created_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&notifications).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, paginated(notifications, page, limit, total))
+}
+
+func markNotificationRead(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var notification Notification
+	if err := db.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&notification).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+		return
+	}
+
+	if notification.ReadAt == nil {
+		now := time.Now()
+		notification.ReadAt = &now
+		if err := db.Save(&notification).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, notification)
+}
+
+func markAllNotificationsRead(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	if err := db.Model(&Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Update("read_at", time.Now()).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "All notifications marked as read"})
+}
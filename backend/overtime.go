@@ -0,0 +1,271 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// overtimeRules decide how worked time beyond the schedule becomes overtime.
+var overtimeRules = struct {
+	DailyThresholdMinutes  int // 0 uses the scheduled minutes of the day
+	WeeklyThresholdMinutes int // 0 disables weekly overtime
+	Multiplier             float64
+	WeekendMultiplier      float64 // for work on non-working days
+	HolidayMultiplier      float64
+	RequiresApproval       bool
+}{
+	WeeklyThresholdMinutes: 2400,
+	Multiplier:             1.5,
+	WeekendMultiplier:      2,
+	HolidayMultiplier:      2,
+	RequiresApproval:       true,
+}
+
+// scheduledMinutes returns how long the schedule expects work on day, less
+// the approved leave fraction. Non-working days and holidays have none.
+func scheduledMinutes(schedule WorkSchedule, day time.Time, holiday bool, leave float64) int {
+	if holiday || !schedule.isWorkingDay(day) {
+		return 0
+	}
+	shift := schedule.endOn(day).Sub(schedule.startOn(day))
+	return int(float64(shift/time.Minute) * (1 - leave))
+}
+
+// weekStart returns the Monday of the week containing day.
+func weekStart(day time.Time) time.Time {
+	return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+}
+
+func minutesToHours(minutes int) float64 {
+	return math.Round(float64(minutes)/60*100) / 100
+}
+
+// recomputeOvertime recalculates scheduled and overtime minutes for every
+// record of user in the week containing date, since weekly overtime depends
+// on the days before. attendance, if it falls in that week, is updated too.
+//
+// Work on holidays and non-working days is overtime in full at its own
+// multiplier. On working days, time beyond the daily threshold is overtime,
+// and the remaining regular time counts towards the weekly threshold.
+func recomputeOvertime(tx *gorm.DB, user User, date string, attendance *Attendance) error {
+	loc := userLocation(user)
+	day, err := parseDate(date, loc)
+	if err != nil {
+		return err
+	}
+	start := weekStart(day)
+	end := start.AddDate(0, 0, 6)
+	startKey, endKey := start.Format(dateLayout), end.Format(dateLayout)
+
+	var records []Attendance
+	if err := tx.Where("user_id = ? AND date BETWEEN ? AND ?", user.ID, startKey, endKey).
+		Order("date, id").
+		Find(&records).Error; err != nil {
+		return err
+	}
+
+	holidays, err := holidaysFor(tx, user, startKey, endKey)
+	if err != nil {
+		return err
+	}
+	leave, err := approvedLeaveByDate(tx, user, start, end)
+	if err != nil {
+		return err
+	}
+	schedule := scheduleFor(user)
+
+	regularSoFar := 0
+	for i := range records {
+		record := &records[i]
+		key := string(record.Date)
+		recordDay, err := parseDate(key, loc)
+		if err != nil {
+			return err
+		}
+		_, holiday := holidays[key]
+		record.ScheduledMinutes = scheduledMinutes(schedule, recordDay, holiday, leave[key])
+
+		// Overtime is only known once the shift is over
+		overtime, multiplier := 0, overtimeRules.Multiplier
+		switch {
+		case record.CheckIn == nil || record.CheckOut == nil:
+		case holiday:
+			overtime, multiplier = record.WorkedMinutes, overtimeRules.HolidayMultiplier
+		case !schedule.isWorkingDay(recordDay):
+			overtime, multiplier = record.WorkedMinutes, overtimeRules.WeekendMultiplier
+		default:
+			threshold := overtimeRules.DailyThresholdMinutes
+			if threshold <= 0 {
+				threshold = record.ScheduledMinutes
+			}
+			daily := max(0, record.WorkedMinutes-threshold)
+			regular := record.WorkedMinutes - daily
+
+			weekly := 0
+			if overtimeRules.WeeklyThresholdMinutes > 0 {
+				weekly = min(regular, max(0, regularSoFar+regular-overtimeRules.WeeklyThresholdMinutes))
+			}
+			regularSoFar += regular - weekly
+			overtime = daily + weekly
+		}
+		setOvertime(record, overtime, multiplier)
+
+		if err := tx.Model(record).
+			Select("scheduled_minutes", "overtime_minutes", "overtime_multiplier", "overtime_status", "overtime_reviewed_by", "overtime_reviewed_at").
+			Updates(record).Error; err != nil {
+			return err
+		}
+
+		if attendance != nil && attendance.ID == record.ID {
+			attendance.ScheduledMinutes = record.ScheduledMinutes
+			attendance.OvertimeMinutes = record.OvertimeMinutes
+			attendance.OvertimeMultiplier = record.OvertimeMultiplier
+			attendance.OvertimeStatus = record.OvertimeStatus
+			attendance.OvertimeReviewedBy = record.OvertimeReviewedBy
+			attendance.OvertimeReviewedAt = record.OvertimeReviewedAt
+		}
+	}
+	return nil
+}
+
+// setOvertime stores newly calculated overtime on a record. Overtime that
+// changed needs a new review; unchanged overtime keeps its decision.
+func setOvertime(attendance *Attendance, minutes int, multiplier float64) {
+	if minutes == 0 {
+		multiplier = 0
+	}
+	if minutes == attendance.OvertimeMinutes && attendance.OvertimeStatus != "" {
+		attendance.OvertimeMultiplier = multiplier
+		return
+	}
+
+	attendance.OvertimeMinutes = minutes
+	attendance.OvertimeMultiplier = multiplier
+	attendance.OvertimeReviewedBy = nil
+	attendance.OvertimeReviewedAt = nil
+	switch {
+	case minutes == 0:
+		attendance.OvertimeStatus = ""
+	case overtimeRules.RequiresApproval:
+		attendance.OvertimeStatus = "pending"
+	default:
+		attendance.OvertimeStatus = "approved"
+	}
+}
+
+func getOvertime(c *gin.Context) {
+	page, limit := parsePagination(c)
+	offset := (page - 1) * limit
+
+	query := db.Model(&Attendance{}).Where("overtime_minutes > 0")
+
+	// Apply filters
+	if status := c.Query("status"); status != "" {
+		query = query.Where("overtime_status = ?", status)
+	}
+	if userIDParam := c.Query("user_id"); userIDParam != "" {
+		if userID, err := strconv.ParseUint(userIDParam, 10, 32); err == nil {
+			query = query.Where("user_id = ?", userID)
+		}
+	}
+	if startDate := c.Query("start_date"); startDate != "" {
+		query = query.Where("date >= ?", startDate)
+	}
+	if endDate := c.Query("end_date"); endDate != "" {
+		query = query.Where("date <= ?", endDate)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var attendances []Attendance
+	if err := query.Preload("User").
+		Order("date DESC, id DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&attendances).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch overtime"})
+		return
+	}
+
+	c.JSON(http.StatusOK, paginated(attendances, page, limit, total))
+}
+
+func approveOvertime(c *gin.Context) {
+	reviewOvertime(c, "approved")
+}
+
+func rejectOvertime(c *gin.Context) {
+	reviewOvertime(c, "rejected")
+}
+
+var errOvertimeNotPending = errors.New("Only pending overtime can be reviewed")
+
+func reviewOvertime(c *gin.Context, status string) {
+	reviewerID, _ := c.Get("user_id")
+
+	var req ReviewRequest
+	if err := bindOptionalJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var attendance Attendance
+	if err := db.Preload("User").First(&attendance, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attendance record not found"})
+		return
+	}
+
+	if attendance.OvertimeStatus != "pending" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errOvertimeNotPending.Error()})
+		return
+	}
+
+	if isPeriodLocked(attendance.UserID, string(attendance.Date), string(attendance.Date)) {
+		c.JSON(http.StatusConflict, gin.H{"error": errPeriodLocked.Error()})
+		return
+	}
+
+	now := time.Now()
+	reviewer := reviewerID.(uint)
+	attendance.OvertimeStatus = status
+	attendance.OvertimeReviewedBy = &reviewer
+	attendance.OvertimeReviewedAt = &now
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// An approve and a reject at the same time must not both go through
+		result := tx.Model(&attendance).
+			Where("overtime_status = ?", "pending").
+			Select("overtime_status", "overtime_reviewed_by", "overtime_reviewed_at").
+			Updates(&attendance)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errOvertimeNotPending
+		}
+
+		message := fmt.Sprintf("Your %d minutes of overtime on %s were %s.", attendance.OvertimeMinutes, attendance.Date, status)
+		if req.Note != "" {
+			message += " " + req.Note
+		}
+		return notify(tx, attendance.UserID, "overtime_reviewed", "Overtime "+status, message, &attendance.ID)
+	})
+	if errors.Is(err, errOvertimeNotPending) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to review overtime"})
+		return
+	}
+
+	c.JSON(http.StatusOK, attendance)
+}
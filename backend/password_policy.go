@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bufio"
+	_ "embed"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// passwordPolicy holds the rules new passwords must meet.
+var passwordPolicy = struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	RejectCommon  bool // reject passwords on the common password list
+	HistorySize   int  // reject reusing the last this many passwords, the current one included; 0 allows reuse
+}{
+	MinLength:    8,
+	RequireUpper: true,
+	RequireLower: true,
+	RequireDigit: true,
+	RejectCommon: true,
+	HistorySize:  3,
+}
+
+//go:embed common_passwords.txt
+var commonPasswordList string
+
+// commonPasswords is the embedded list, plus PASSWORD_BLOCKLIST_FILE when
+// set, lower-cased.
+var commonPasswords = parsePasswordList(commonPasswordList)
+
+// parsePasswordList reads one password per line, skipping blank lines and
+// # comments.
+func parsePasswordList(list string) map[string]bool {
+	passwords := map[string]bool{}
+	scanner := bufio.NewScanner(strings.NewReader(list))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			passwords[strings.ToLower(line)] = true
+		}
+	}
+	return passwords
+}
+
+// loadPasswordBlocklist adds the passwords of a file to the common list.
+func loadPasswordBlocklist(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	for password := range parsePasswordList(string(data)) {
+		commonPasswords[password] = true
+	}
+	return nil
+}
+
+// validatePassword checks password against the policy and returns an error
+// describing the first rule it breaks.
+func validatePassword(password string) error {
+	if len([]rune(password)) < passwordPolicy.MinLength {
+		return fmt.Errorf("Password must be at least %d characters long", passwordPolicy.MinLength)
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case !unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	switch {
+	case passwordPolicy.RequireUpper && !upper:
+		return errors.New("Password must contain an uppercase letter")
+	case passwordPolicy.RequireLower && !lower:
+		return errors.New("Password must contain a lowercase letter")
+	case passwordPolicy.RequireDigit && !digit:
+		return errors.New("Password must contain a digit")
+	case passwordPolicy.RequireSymbol && !symbol:
+		return errors.New("Password must contain a symbol")
+	}
+
+	if passwordPolicy.RejectCommon && commonPasswords[strings.ToLower(password)] {
+		return errors.New("Password is too common, choose a different one")
+	}
+	return nil
+}
+
+// passwordReused reports whether password is one of user's last passwords,
+// the current one included. Each comparison is a bcrypt check, so the
+// history should stay short.
+func passwordReused(user User, password string) (bool, error) {
+	if passwordPolicy.HistorySize <= 0 {
+		return false, nil
+	}
+	if checkPassword(password, user.Password) {
+		return true, nil
+	}
+	if passwordPolicy.HistorySize == 1 {
+		return false, nil
+	}
+
+	var history []PasswordHistory
+	if err := db.Where("user_id = ?", user.ID).
+		Order("created_at DESC, id DESC").
+		Limit(passwordPolicy.HistorySize - 1).
+		Find(&history).Error; err != nil {
+		return false, err
+	}
+	for _, entry := range history {
+		if checkPassword(password, entry.PasswordHash) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// checkNewPassword applies the whole policy to a password user wants to set.
+// It reports whether the password is acceptable; if not, an error response
+// has been sent.
+func checkNewPassword(c *gin.Context, user User, password string) bool {
+	if err := validatePassword(password); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	reused, err := passwordReused(user, password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check password history"})
+		return false
+	}
+	if reused {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password was used recently, choose a different one"})
+		return false
+	}
+	return true
+}
+
+// setPassword replaces user's password with hashedPassword, keeps the old
+// one in the history and invalidates the user's access tokens.
+func setPassword(tx *gorm.DB, user User, hashedPassword string) error {
+	if previous := passwordPolicy.HistorySize - 1; previous > 0 {
+		if err := tx.Create(&PasswordHistory{UserID: user.ID, PasswordHash: user.Password}).Error; err != nil {
+			return err
+		}
+
+		// Forget what the policy no longer looks at
+		keep := tx.Model(&PasswordHistory{}).Select("id").
+			Where("user_id = ?", user.ID).
+			Order("created_at DESC, id DESC").
+			Limit(previous)
+		if err := tx.Where("user_id = ? AND id NOT IN (?)", user.ID, keep).Delete(&PasswordHistory{}).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Model(&User{}).Where("id = ?", user.ID).Update("password", hashedPassword).Error; err != nil {
+		return err
+	}
+	return invalidateTokens(tx, user.ID)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidatePassword(t *testing.T) {
+	saved := passwordPolicy
+	t.Cleanup(func() { passwordPolicy = saved })
+
+	strict := func() {
+		passwordPolicy.MinLength = 12
+		passwordPolicy.RequireSymbol = true
+	}
+	lenient := func() {
+		passwordPolicy.MinLength = 4
+		passwordPolicy.RequireUpper = false
+		passwordPolicy.RequireLower = false
+		passwordPolicy.RequireDigit = false
+		passwordPolicy.RejectCommon = false
+	}
+
+	tests := []struct {
+		name     string
+		policy   func() // adjusts the default policy
+		password string
+		wantErr  string
+	}{
+		{name: "acceptable", password: "Tulip7garden"},
+		{name: "too short", password: "Ab1defg", wantErr: "at least 8 characters"},
+		{name: "length counts characters, not bytes", password: "Äb1ödüßé"},
+		{name: "no uppercase", password: "tulip7garden", wantErr: "uppercase"},
+		{name: "no lowercase", password: "TULIP7GARDEN", wantErr: "lowercase"},
+		{name: "no digit", password: "Tulipgarden", wantErr: "digit"},
+		{name: "common", password: "Password1", wantErr: "too common"},
+		{name: "common in any case", password: "pASSWORD1", wantErr: "too common"},
+		{name: "symbol required", policy: strict, password: "Tulip7gardens", wantErr: "symbol"},
+		{name: "spaces are not symbols", policy: strict, password: "Tulip7 garden", wantErr: "symbol"},
+		{name: "symbol given", policy: strict, password: "Tulip7garden!"},
+		{name: "longer minimum", policy: strict, password: "Tulip7garn!", wantErr: "at least 12 characters"},
+		{name: "rules switched off", policy: lenient, password: "password"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			passwordPolicy = saved
+			if tt.policy != nil {
+				tt.policy()
+			}
+
+			err := validatePassword(tt.password)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("validatePassword(%q) = %v, want nil", tt.password, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("validatePassword(%q) = %v, want an error containing %q", tt.password, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParsePasswordList(t *testing.T) {
+	list := parsePasswordList("# comment\n\nHunter2\n  letmein  \r\n#not-a-password\n")
+
+	want := []string{"hunter2", "letmein"}
+	if len(list) != len(want) {
+		t.Fatalf("got %d passwords %v, want %v", len(list), list, want)
+	}
+	for _, password := range want {
+		if !list[password] {
+			t.Errorf("%q missing from %v", password, list)
+		}
+	}
+}
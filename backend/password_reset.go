@@ -0,0 +1,140 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// passwordResetTTL is how long a password reset link works.
+var passwordResetTTL = 30 * time.Minute
+
+var errInvalidResetToken = errors.New("Invalid or expired reset token")
+
+// forgotPassword emails a password reset link. The response is the same
+// whether or not the email is registered, and the link is created and sent
+// in the background so that the response time does not tell either.
+func forgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user User
+	if err := db.Where("email = ?", req.Email).First(&user).Error; err == nil {
+		go sendPasswordReset(user)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "If the email is registered, a password reset link has been sent"})
+}
+
+// sendPasswordReset creates a reset token for user, replacing any earlier
+// one, and mails the link to them.
+func sendPasswordReset(user User) {
+	token, hash, err := newSecretToken()
+	if err != nil {
+		log.Printf("Failed to create password reset token for user %d: %v", user.ID, err)
+		return
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", user.ID).Delete(&PasswordResetToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&PasswordResetToken{UserID: user.ID, TokenHash: hash, ExpiresAt: time.Now().Add(passwordResetTTL)}).Error
+	})
+	if err != nil {
+		log.Printf("Failed to store password reset token for user %d: %v", user.ID, err)
+		return
+	}
+
+	link := appURL + "/reset-password?token=" + url.QueryEscape(token)
+	if err := mailer.Send(Mail{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nA password reset was requested for your account. "+
+			"Open the link below within %d minutes to choose a new password:\n\n%s\n\n"+
+			"If you did not ask for this, you can ignore this email; your password has not changed.\n",
+			user.Name, int(passwordResetTTL/time.Minute), link),
+	}); err != nil {
+		log.Printf("Failed to send password reset email to user %d: %v", user.ID, err)
+	}
+}
+
+// resetPassword sets a new password with a reset token. The token cannot be
+// used again, and the user is signed out everywhere.
+func resetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var stored PasswordResetToken
+	if err := db.Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", hashToken(req.Token), time.Now()).
+		First(&stored).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errInvalidResetToken.Error()})
+		return
+	}
+	var user User
+	if err := db.First(&user, stored.UserID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errInvalidResetToken.Error()})
+		return
+	}
+
+	// The policy is checked before the token is used up, so a rejected
+	// password can be corrected with the same link
+	if !checkNewPassword(c, user, req.Password) {
+		return
+	}
+
+	// Hashing is slow, so it happens before the transaction takes its lock
+	hashedPassword, err := hashPassword(req.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Claim the token so that a concurrent request cannot use it too
+		result := tx.Model(&PasswordResetToken{}).
+			Where("id = ? AND used_at IS NULL", stored.ID).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errInvalidResetToken
+		}
+
+		if err := setPassword(tx, user, hashedPassword); err != nil {
+			return err
+		}
+		_, err := revokeSessions(tx, user.ID)
+		return err
+	})
+	if errors.Is(err, errInvalidResetToken) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully"})
+}
+
+// deleteExpiredResetTokens removes reset tokens that can no longer be used.
+func deleteExpiredResetTokens(now time.Time) {
+	if err := db.Where("expires_at < ? OR used_at IS NOT NULL", now).Delete(&PasswordResetToken{}).Error; err != nil {
+		log.Printf("expire-password-resets: %v", err)
+	}
+}
@@ -0,0 +1,498 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// payrollPeriodDays is the length of a pay period, counted from payPeriodStart.
+var payrollPeriodDays = 14
+
+// payrollLayout maps payroll totals to the columns of an export file, so
+// each payroll vendor's format can be configured without code changes.
+type payrollLayout struct {
+	Format     string          `json:"format"`      // csv or fixed
+	Delimiter  string          `json:"delimiter"`   // csv only, defaults to a comma
+	Header     bool            `json:"header"`      // write a header row
+	DateFormat string          `json:"date_format"` // Go time layout for dates, defaults to 2006-01-02
+	Columns    []payrollColumn `json:"columns"`
+}
+
+// payrollColumn is one column of a payroll layout. Field selects a value from
+// payrollFields, "leave:<code>" for days of one leave type or "leave:*" for a
+// column per leave type. Without a field the constant Value is written.
+type payrollColumn struct {
+	Header         string `json:"header"` // "{code}" is replaced by the leave type code for leave:*
+	Field          string `json:"field"`
+	Value          string `json:"value"`
+	Width          int    `json:"width"`           // fixed only
+	Align          string `json:"align"`           // left or right; numbers default to right
+	Pad            string `json:"pad"`             // fixed only, defaults to a space
+	Decimals       *int   `json:"decimals"`        // for hours and days, defaults to 2
+	ImpliedDecimal bool   `json:"implied_decimal"` // drop the decimal point, e.g. 7.50 as 750
+}
+
+// payrollFields lists the fields a payroll column can use and whether each
+// is numeric.
+var payrollFields = map[string]bool{
+	"employee_id":        true,
+	"email":              false,
+	"name":               false,
+	"position":           false,
+	"department":         false,
+	"period_start":       false,
+	"period_end":         false,
+	"worked_hours":       true,
+	"regular_hours":      true,
+	"overtime_hours":     true,
+	"overtime_pay_hours": true,
+	"late_count":         true,
+	"half_day_count":     true,
+	"absent_days":        true,
+	"leave_days":         true,
+}
+
+// payrollLayouts holds the layouts selectable with the layout query
+// parameter. The built-in ones can be replaced from PAYROLL_LAYOUT_FILE.
+var payrollLayouts = map[string]payrollLayout{
+	"csv": {
+		Format: "csv",
+		Header: true,
+		Columns: []payrollColumn{
+			{Header: "employee_id", Field: "employee_id"},
+			{Header: "email", Field: "email"},
+			{Header: "name", Field: "name"},
+			{Header: "department", Field: "department"},
+			{Header: "period_start", Field: "period_start"},
+			{Header: "period_end", Field: "period_end"},
+			{Header: "regular_hours", Field: "regular_hours"},
+			{Header: "overtime_hours", Field: "overtime_hours"},
+			{Header: "overtime_pay_hours", Field: "overtime_pay_hours"},
+			{Header: "late_count", Field: "late_count"},
+			{Header: "absent_days", Field: "absent_days"},
+			{Header: "leave_{code}", Field: "leave:*"},
+		},
+	},
+	"fixed": {
+		Format:     "fixed",
+		DateFormat: "20060102",
+		Columns: []payrollColumn{
+			{Field: "employee_id", Width: 8, Pad: "0"},
+			{Field: "name", Width: 30},
+			{Field: "period_start", Width: 8},
+			{Field: "period_end", Width: 8},
+			{Field: "regular_hours", Width: 7},
+			{Field: "overtime_hours", Width: 7},
+			{Field: "overtime_pay_hours", Width: 7},
+			{Field: "late_count", Width: 3},
+			{Field: "absent_days", Width: 5},
+			{Field: "leave_days", Width: 6},
+		},
+	},
+}
+
+// loadPayrollLayouts reads named layouts from a JSON file, adding to or
+// replacing the built-in ones.
+func loadPayrollLayouts(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var layouts map[string]payrollLayout
+	if err := json.Unmarshal(data, &layouts); err != nil {
+		return err
+	}
+	for name, layout := range layouts {
+		if err := layout.validate(); err != nil {
+			return fmt.Errorf("layout %q: %w", name, err)
+		}
+		payrollLayouts[name] = layout
+	}
+	return nil
+}
+
+func (l payrollLayout) validate() error {
+	if l.Format != "csv" && l.Format != "fixed" {
+		return errors.New("format must be csv or fixed")
+	}
+	if len(l.Columns) == 0 {
+		return errors.New("at least one column is required")
+	}
+	if l.Format == "csv" && len([]rune(l.Delimiter)) > 1 {
+		return errors.New("delimiter must be a single character")
+	}
+	for i, column := range l.Columns {
+		if column.Field != "" && !strings.HasPrefix(column.Field, "leave:") {
+			if _, ok := payrollFields[column.Field]; !ok {
+				return fmt.Errorf("column %d: unknown field %q", i+1, column.Field)
+			}
+		}
+		if l.Format == "fixed" && column.Width <= 0 {
+			return fmt.Errorf("column %d: fixed-width columns need a width", i+1)
+		}
+		if len([]rune(column.Pad)) > 1 {
+			return fmt.Errorf("column %d: pad must be a single character", i+1)
+		}
+		if column.Align != "" && column.Align != "left" && column.Align != "right" {
+			return fmt.Errorf("column %d: align must be left or right", i+1)
+		}
+	}
+	return nil
+}
+
+// expandColumns replaces a leave:* column with one column per leave type.
+func (l payrollLayout) expandColumns(leaveTypes []LeaveType) []payrollColumn {
+	var columns []payrollColumn
+	for _, column := range l.Columns {
+		if column.Field != "leave:*" {
+			columns = append(columns, column)
+			continue
+		}
+		for _, leaveType := range leaveTypes {
+			expanded := column
+			expanded.Field = "leave:" + leaveType.Code
+			expanded.Header = strings.ReplaceAll(column.Header, "{code}", leaveType.Code)
+			columns = append(columns, expanded)
+		}
+	}
+	return columns
+}
+
+// payrollRow holds one employee's totals for a pay period.
+type payrollRow struct {
+	user               User
+	start, end         time.Time
+	workedMinutes      int
+	overtimeMinutes    int // all calculated overtime, approved or not
+	approvedOvertime   int
+	overtimePayMinutes float64 // approved overtime weighted by its multiplier
+	lateCount          int
+	halfDayCount       int
+	absentDays         int
+	leave              map[string]float64 // days by leave type code
+}
+
+// value returns the unformatted value of a field, a string or a float64.
+func (r payrollRow) value(field, dateFormat string) interface{} {
+	if code, ok := strings.CutPrefix(field, "leave:"); ok {
+		return r.leave[code]
+	}
+
+	switch field {
+	case "employee_id":
+		return float64(r.user.ID)
+	case "email":
+		return r.user.Email
+	case "name":
+		return r.user.Name
+	case "position":
+		return r.user.Position
+	case "department":
+		return r.user.Department
+	case "period_start":
+		return r.start.Format(dateFormat)
+	case "period_end":
+		return r.end.Format(dateFormat)
+	case "worked_hours":
+		return float64(r.workedMinutes) / 60
+	case "regular_hours":
+		// Overtime is paid separately, or not at all if rejected
+		return float64(r.workedMinutes-r.overtimeMinutes) / 60
+	case "overtime_hours":
+		return float64(r.approvedOvertime) / 60
+	case "overtime_pay_hours":
+		return r.overtimePayMinutes / 60
+	case "late_count":
+		return float64(r.lateCount)
+	case "half_day_count":
+		return float64(r.halfDayCount)
+	case "absent_days":
+		return float64(r.absentDays)
+	case "leave_days":
+		total := 0.0
+		for _, days := range r.leave {
+			total += days
+		}
+		return total
+	}
+	return ""
+}
+
+// format renders a column of the row as text, before any fixed-width padding.
+func (r payrollRow) format(column payrollColumn, dateFormat string) string {
+	if column.Field == "" {
+		return column.Value
+	}
+
+	switch v := r.value(column.Field, dateFormat).(type) {
+	case float64:
+		decimals := 2
+		if column.Decimals != nil {
+			decimals = *column.Decimals
+		}
+		// Counts and ids are whole numbers
+		if payrollWholeFields[column.Field] {
+			decimals = 0
+		}
+		text := strconv.FormatFloat(v, 'f', decimals, 64)
+		if column.ImpliedDecimal {
+			text = strings.Replace(text, ".", "", 1)
+		}
+		return text
+	case string:
+		return v
+	}
+	return ""
+}
+
+var payrollWholeFields = map[string]bool{
+	"employee_id":    true,
+	"late_count":     true,
+	"half_day_count": true,
+	"absent_days":    true,
+}
+
+// numeric reports whether the column holds a number rather than text.
+func (column payrollColumn) numeric() bool {
+	return payrollFields[column.Field] || strings.HasPrefix(column.Field, "leave:")
+}
+
+// fitWidth pads a value to a fixed width. Text that is too long is cut
+// off, but a number that does not fit is an error, since cutting it would
+// change the amount.
+func fitWidth(value string, column payrollColumn) (string, error) {
+	numeric := column.numeric()
+
+	runes := []rune(value)
+	if len(runes) > column.Width {
+		if numeric {
+			return "", fmt.Errorf("Value %s of column %s does not fit in %d characters", value, column.Field, column.Width)
+		}
+		return string(runes[:column.Width]), nil
+	}
+
+	pad := " "
+	if column.Pad != "" {
+		pad = column.Pad
+	}
+	right := column.Align == "right" || (column.Align == "" && numeric)
+
+	padding := strings.Repeat(pad, column.Width-len(runes))
+	if right {
+		return padding + value, nil
+	}
+	return value + padding, nil
+}
+
+// payrollPeriod returns the pay period to export. Explicit start_date and
+// end_date win; otherwise the period containing date is used, or the last
+// completed period.
+func payrollPeriod(c *gin.Context) (time.Time, time.Time, error) {
+	startParam, endParam := c.Query("start_date"), c.Query("end_date")
+	if startParam != "" || endParam != "" {
+		start, err := parseDate(startParam, time.UTC)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("Invalid start_date, expected YYYY-MM-DD")
+		}
+		end, err := parseDate(endParam, time.UTC)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("Invalid end_date, expected YYYY-MM-DD")
+		}
+		if end.Before(start) {
+			return time.Time{}, time.Time{}, errors.New("end_date must not be before start_date")
+		}
+		return start, end, nil
+	}
+
+	day := time.Now().In(defaultLocation)
+	day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, -payrollPeriodDays)
+	if dateParam := c.Query("date"); dateParam != "" {
+		parsed, err := parseDate(dateParam, time.UTC)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("Invalid date, expected YYYY-MM-DD")
+		}
+		day = parsed
+	}
+
+	start := payPeriodContaining(day, payrollPeriodDays)
+	return start, start.AddDate(0, 0, payrollPeriodDays-1), nil
+}
+
+// buildPayrollRows totals attendance and approved leave from start to end
+// for each user.
+func buildPayrollRows(users []User, start, end time.Time) ([]payrollRow, error) {
+	startKey, endKey := start.Format(dateLayout), end.Format(dateLayout)
+
+	var totals []struct {
+		UserID           uint
+		Worked           int
+		Overtime         int
+		ApprovedOvertime int
+		OvertimePay      float64
+		Late             int
+		HalfDay          int
+		Absent           int
+	}
+	if err := db.Model(&Attendance{}).
+		Select(`user_id,
+			COALESCE(SUM(worked_minutes), 0) AS worked,
+			COALESCE(SUM(overtime_minutes), 0) AS overtime,
+			SUM(CASE WHEN overtime_status = 'approved' THEN overtime_minutes ELSE 0 END) AS approved_overtime,
+			SUM(CASE WHEN overtime_status = 'approved' THEN overtime_minutes * overtime_multiplier ELSE 0 END) AS overtime_pay,
+			SUM(CASE WHEN status = 'late' THEN 1 ELSE 0 END) AS late,
+			SUM(CASE WHEN status = 'half_day' THEN 1 ELSE 0 END) AS half_day,
+			SUM(CASE WHEN status = 'absent' THEN 1 ELSE 0 END) AS absent`).
+		Where("date BETWEEN ? AND ?", startKey, endKey).
+		Group("user_id").
+		Scan(&totals).Error; err != nil {
+		return nil, err
+	}
+
+	var leave []LeaveRequest
+	if err := db.Preload("LeaveType").
+		Where("status = ? AND start_date <= ? AND end_date >= ?", "approved", endKey, startKey).
+		Find(&leave).Error; err != nil {
+		return nil, err
+	}
+
+	rows := make([]payrollRow, len(users))
+	index := make(map[uint]int, len(users))
+	for i, user := range users {
+		rows[i] = payrollRow{user: user, start: start, end: end, leave: make(map[string]float64)}
+		index[user.ID] = i
+	}
+
+	for _, total := range totals {
+		i, ok := index[total.UserID]
+		if !ok {
+			continue
+		}
+		rows[i].workedMinutes = total.Worked
+		rows[i].overtimeMinutes = total.Overtime
+		rows[i].approvedOvertime = total.ApprovedOvertime
+		rows[i].overtimePayMinutes = total.OvertimePay
+		rows[i].lateCount = total.Late
+		rows[i].halfDayCount = total.HalfDay
+		rows[i].absentDays = total.Absent
+	}
+
+	// Count only the part of each leave inside the period
+	for _, request := range leave {
+		i, ok := index[request.UserID]
+		if !ok {
+			continue
+		}
+		user := rows[i].user
+		loc := userLocation(user)
+		from, _ := parseDate(max(string(request.StartDate), startKey), loc)
+		to, _ := parseDate(min(string(request.EndDate), endKey), loc)
+		rows[i].leave[request.LeaveType.Code] += leaveDays(user, from, to, request.HalfDay)
+	}
+
+	return rows, nil
+}
+
+// exportPayroll writes per-employee totals for a pay period in the layout
+// chosen by the layout query parameter, csv by default.
+func exportPayroll(c *gin.Context) {
+	layout, ok := payrollLayouts[c.DefaultQuery("layout", "csv")]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown payroll layout"})
+		return
+	}
+
+	start, end, err := payrollPeriod(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := db.Model(&User{})
+	if department := c.Query("department"); department != "" {
+		query = query.Where("department = ?", department)
+	}
+	var users []User
+	if err := query.Order("id").Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
+		return
+	}
+
+	rows, err := buildPayrollRows(users, start, end)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate payroll"})
+		return
+	}
+
+	var leaveTypes []LeaveType
+	if err := db.Order("id").Find(&leaveTypes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch leave types"})
+		return
+	}
+	columns := layout.expandColumns(leaveTypes)
+
+	dateFormat := layout.DateFormat
+	if dateFormat == "" {
+		dateFormat = dateLayout
+	}
+
+	// The file is built in full first, so that a value that cannot be
+	// exported fails the request instead of producing a broken file
+	var body bytes.Buffer
+	extension, contentType := "csv", "text/csv"
+	if layout.Format == "fixed" {
+		extension, contentType = "txt", "text/plain"
+		for _, row := range rows {
+			for _, column := range columns {
+				field, err := fitWidth(row.format(column, dateFormat), column)
+				if err != nil {
+					c.JSON(http.StatusUnprocessableEntity, gin.H{"error": fmt.Sprintf("Employee %d: %s", row.user.ID, err)})
+					return
+				}
+				body.WriteString(field)
+			}
+			body.WriteString("\r\n")
+		}
+	} else {
+		writer := csv.NewWriter(&body)
+		if layout.Delimiter != "" {
+			writer.Comma = []rune(layout.Delimiter)[0]
+		}
+		if layout.Header {
+			header := make([]string, len(columns))
+			for i, column := range columns {
+				header[i] = csvText(column.Header)
+			}
+			writer.Write(header)
+		}
+		for _, row := range rows {
+			record := make([]string, len(columns))
+			for i, column := range columns {
+				record[i] = row.format(column, dateFormat)
+				if !column.numeric() {
+					record[i] = csvText(record[i])
+				}
+			}
+			writer.Write(record)
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write payroll export"})
+			return
+		}
+	}
+
+	filename := fmt.Sprintf("payroll_%s_%s.%s", start.Format(dateLayout), end.Format(dateLayout), extension)
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Data(http.StatusOK, contentType+"; charset=utf-8", body.Bytes())
+}
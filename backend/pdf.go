@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// A4 page size in points
+const (
+	pdfPageWidth  = 595.0
+	pdfPageHeight = 842.0
+)
+
+// pdfColor is an RGB color with components between 0 and 1.
+type pdfColor struct{ R, G, B float64 }
+
+var (
+	pdfBlack = pdfColor{0, 0, 0}
+	pdfGray  = pdfColor{0.45, 0.45, 0.45}
+)
+
+// Advance widths of the printable ASCII characters, from space to tilde, in
+// thousandths of the font size. Other characters use pdfDefaultWidth.
+var (
+	pdfHelveticaWidths = []int{
+		278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+		1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+		333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+		556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+	}
+	pdfHelveticaBoldWidths = []int{
+		278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+		975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+		333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+		611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+	}
+)
+
+const pdfDefaultWidth = 556
+
+// pdfDocument builds a PDF of A4 pages using the standard Helvetica fonts,
+// which every viewer provides, so no font files need to be embedded.
+// Coordinates are in points from the top-left corner of the page.
+type pdfDocument struct {
+	pages []*bytes.Buffer
+	page  *bytes.Buffer
+}
+
+func newPDFDocument() *pdfDocument {
+	doc := &pdfDocument{}
+	doc.AddPage()
+	return doc
+}
+
+func (d *pdfDocument) AddPage() {
+	d.page = &bytes.Buffer{}
+	d.pages = append(d.pages, d.page)
+}
+
+// Text draws s with its baseline starting at x, y.
+func (d *pdfDocument) Text(x, y, size float64, bold bool, color pdfColor, s string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(d.page, "BT %.3f %.3f %.3f rg /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n",
+		color.R, color.G, color.B, font, size, x, pdfPageHeight-y, pdfEscape(s))
+}
+
+// TextRight draws s so that it ends at x.
+func (d *pdfDocument) TextRight(x, y, size float64, bold bool, color pdfColor, s string) {
+	d.Text(x-pdfTextWidth(s, size, bold), y, size, bold, color, s)
+}
+
+// Rect draws a rectangle whose top-left corner is at x, y, filled with fill
+// if it is not nil and outlined with stroke if that is not nil.
+func (d *pdfDocument) Rect(x, y, w, h float64, fill, stroke *pdfColor) {
+	op := ""
+	switch {
+	case fill != nil && stroke != nil:
+		op = "B"
+	case fill != nil:
+		op = "f"
+	case stroke != nil:
+		op = "S"
+	default:
+		return
+	}
+	if fill != nil {
+		fmt.Fprintf(d.page, "%.3f %.3f %.3f rg ", fill.R, fill.G, fill.B)
+	}
+	if stroke != nil {
+		fmt.Fprintf(d.page, "%.3f %.3f %.3f RG 0.5 w ", stroke.R, stroke.G, stroke.B)
+	}
+	fmt.Fprintf(d.page, "%.2f %.2f %.2f %.2f re %s\n", x, pdfPageHeight-y-h, w, h, op)
+}
+
+// Line draws a thin line from x1, y1 to x2, y2.
+func (d *pdfDocument) Line(x1, y1, x2, y2 float64, color pdfColor) {
+	fmt.Fprintf(d.page, "%.3f %.3f %.3f RG 0.5 w %.2f %.2f m %.2f %.2f l S\n",
+		color.R, color.G, color.B, x1, pdfPageHeight-y1, x2, pdfPageHeight-y2)
+}
+
+// WriteTo writes the finished document to w.
+func (d *pdfDocument) WriteTo(w io.Writer) (int64, error) {
+	var out bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	// Objects 1-4 are the catalog, page tree and fonts; each page then takes
+	// two objects, the page and its content stream.
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for i, page := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] "+
+			"/Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, 6+2*i))
+
+		var content bytes.Buffer
+		zw := zlib.NewWriter(&content)
+		zw.Write(page.Bytes())
+		zw.Close()
+		object(fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", content.Len(), content.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return out.WriteTo(w)
+}
+
+// pdfTextWidth returns the width of s in points.
+func pdfTextWidth(s string, size float64, bold bool) float64 {
+	widths := pdfHelveticaWidths
+	if bold {
+		widths = pdfHelveticaBoldWidths
+	}
+	total := 0
+	for _, r := range s {
+		if r >= ' ' && r <= '~' {
+			total += widths[r-' ']
+		} else {
+			total += pdfDefaultWidth
+		}
+	}
+	return float64(total) * size / 1000
+}
+
+// pdfTruncate shortens s with an ellipsis so it fits in width.
+func pdfTruncate(s string, width, size float64, bold bool) string {
+	if pdfTextWidth(s, size, bold) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 && pdfTextWidth(string(runes)+"...", size, bold) > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "..."
+}
+
+// pdfEscape encodes s as the body of a PDF string literal. The fonts use
+// WinAnsiEncoding, which matches Latin-1 for the characters kept here;
+// anything outside it is replaced with a question mark.
+func pdfEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= ' ' && r <= '~':
+			b.WriteRune(r)
+		case r >= 0xA0 && r <= 0xFF:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
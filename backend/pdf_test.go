@@ -0,0 +1,77 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPDFEscape(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"Attendance report", "Attendance report"},
+		{"Notes (late)", `Notes \(late\)`},
+		{`C:\path`, `C:\\path`},
+		{"café", `caf\351`},
+		{"Müller\u00a0Straße", `M\374ller\240Stra\337e`},
+		{"10 €", "10 ?"},
+		{"line\nbreak\ttab", "line?break?tab"},
+		{"日本", "??"},
+	}
+
+	for _, tt := range tests {
+		if got := pdfEscape(tt.in); got != tt.want {
+			t.Errorf("pdfEscape(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestPDFTextWidth(t *testing.T) {
+	tests := []struct {
+		text string
+		size float64
+		bold bool
+		want float64
+	}{
+		{"", 10, false, 0},
+		{" ", 10, false, 2.78},
+		{"Hi", 10, false, 9.44},
+		{"Hi", 10, true, 10},
+		{"Hi", 20, false, 18.88},
+		{"WWW", 12, false, 33.984},
+		{"~", 10, false, 5.84},
+		// Characters without a width in the table count as an average glyph
+		{"é", 10, false, 5.56},
+		{"é", 10, true, 5.56},
+	}
+
+	for _, tt := range tests {
+		if got := pdfTextWidth(tt.text, tt.size, tt.bold); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("pdfTextWidth(%q, %g, %t) = %g, want %g", tt.text, tt.size, tt.bold, got, tt.want)
+		}
+	}
+}
+
+func TestPDFTruncate(t *testing.T) {
+	tests := []struct {
+		text  string
+		width float64
+		want  string
+	}{
+		{"Hi", 9.44, "Hi"},
+		{"Hello world", 100, "Hello world"},
+		{"Hello world", 30, "Hell..."},
+		{"Hello world", 5, "..."},
+	}
+
+	for _, tt := range tests {
+		got := pdfTruncate(tt.text, tt.width, 10, false)
+		if got != tt.want {
+			t.Errorf("pdfTruncate(%q, %g) = %q, want %q", tt.text, tt.width, got, tt.want)
+		}
+		if got != tt.text && pdfTextWidth(got, 10, false) > tt.width && got != "..." {
+			t.Errorf("pdfTruncate(%q, %g) = %q is wider than the limit", tt.text, tt.width, got)
+		}
+	}
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const reportMargin = 40.0
+
+// reportStatusColors tint the calendar cells by day status.
+var reportStatusColors = map[string]pdfColor{
+	"present":  {0.85, 0.95, 0.87},
+	"late":     {1, 0.93, 0.8},
+	"half_day": {0.99, 0.97, 0.8},
+	"absent":   {0.99, 0.87, 0.87},
+	"leave":    {0.86, 0.91, 0.99},
+	"holiday":  {0.93, 0.89, 0.98},
+	"off":      {0.95, 0.95, 0.95},
+}
+
+var reportStatusLabels = map[string]string{
+	"present":  "Present",
+	"late":     "Late",
+	"half_day": "Half day",
+	"absent":   "Absent",
+	"leave":    "Leave",
+	"holiday":  "Holiday",
+	"off":      "Off",
+}
+
+// reportDay is one calendar cell of the monthly report.
+type reportDay struct {
+	Date   time.Time
+	Status string // empty for days still to come
+	Detail string // holiday name or leave type
+	Record *Attendance
+}
+
+// reportMonth parses the month query parameter, YYYY-MM in loc, defaulting
+// to the current month. It returns the first and last day of the month.
+func reportMonth(c *gin.Context, loc *time.Location) (time.Time, time.Time, error) {
+	now := time.Now().In(loc)
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
+	if month := c.Query("month"); month != "" {
+		parsed, err := time.ParseInLocation("2006-01", month, loc)
+		if err != nil {
+			return start, start, errors.New("Invalid month, expected YYYY-MM")
+		}
+		start = parsed
+	}
+	return start, start.AddDate(0, 1, -1), nil
+}
+
+// reportDays works out the status of every day of user's month from the
+// attendance records, holidays, approved leave and work schedule.
+func reportDays(user User, start, end time.Time, leaveRequests []LeaveRequest) ([]reportDay, error) {
+	startKey, endKey := start.Format(dateLayout), end.Format(dateLayout)
+
+	var records []Attendance
+	if err := db.Where("user_id = ? AND date BETWEEN ? AND ?", user.ID, startKey, endKey).
+		Find(&records).Error; err != nil {
+		return nil, err
+	}
+	byDate := make(map[string]*Attendance, len(records))
+	for i := range records {
+		byDate[string(records[i].Date)] = &records[i]
+	}
+
+	holidays, err := holidaysFor(db, user, startKey, endKey)
+	if err != nil {
+		return nil, err
+	}
+	leave, err := approvedLeaveByDate(db, user, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	schedule := scheduleFor(user)
+	today := time.Now().In(userLocation(user)).Format(dateLayout)
+
+	var days []reportDay
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		key := d.Format(dateLayout)
+		day := reportDay{Date: d, Record: byDate[key]}
+		holiday, isHoliday := holidays[key]
+
+		switch {
+		case day.Record != nil:
+			day.Status = day.Record.Status
+			if isHoliday {
+				day.Detail = holiday.Name
+			}
+		case isHoliday:
+			day.Status, day.Detail = "holiday", holiday.Name
+		case leave[key] > 0:
+			day.Status = "leave"
+			for _, request := range leaveRequests {
+				if string(request.StartDate) <= key && key <= string(request.EndDate) {
+					day.Detail = request.LeaveType.Name
+					break
+				}
+			}
+		case !schedule.isWorkingDay(d):
+			day.Status = "off"
+		case key < today:
+			day.Status = "absent"
+		}
+		days = append(days, day)
+	}
+	return days, nil
+}
+
+// renderAttendanceReport draws the monthly report: a calendar of day
+// statuses with check-in and check-out times, the month's totals and the
+// leave taken.
+func renderAttendanceReport(user User, start, end time.Time) (*pdfDocument, error) {
+	var leaveRequests []LeaveRequest
+	if err := db.Preload("LeaveType").
+		Where("user_id = ? AND status = ? AND start_date <= ? AND end_date >= ?",
+			user.ID, "approved", end.Format(dateLayout), start.Format(dateLayout)).
+		Order("start_date").
+		Find(&leaveRequests).Error; err != nil {
+		return nil, err
+	}
+
+	days, err := reportDays(user, start, end, leaveRequests)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := computeAttendanceStats(user, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	loc := userLocation(user)
+	doc := newPDFDocument()
+	right := pdfPageWidth - reportMargin
+	width := right - reportMargin
+
+	// Header
+	doc.Text(reportMargin, 60, 18, true, pdfBlack, "Attendance Report - "+start.Format("January 2006"))
+	doc.Text(reportMargin, 82, 12, true, pdfBlack, user.Name)
+	details := user.Email
+	for _, detail := range []string{user.Position, user.Department} {
+		if detail != "" {
+			details += "  |  " + detail
+		}
+	}
+	doc.Text(reportMargin, 96, 9, false, pdfGray, details)
+	doc.TextRight(right, 96, 9, false, pdfGray, "Generated "+time.Now().In(loc).Format("2006-01-02 15:04"))
+
+	// Calendar grid, weeks starting on Monday
+	cellWidth, cellHeight := width/7, 60.0
+	y := 116.0
+	for i, name := range []string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"} {
+		doc.Text(reportMargin+float64(i)*cellWidth+4, y+10, 9, true, pdfGray, name)
+	}
+	y += 16
+
+	border := pdfGray
+	offset := (int(start.Weekday()) + 6) % 7
+	for i, day := range days {
+		col, row := (offset+i)%7, (offset+i)/7
+		x, top := reportMargin+float64(col)*cellWidth, y+float64(row)*cellHeight
+
+		var fill *pdfColor
+		if color, ok := reportStatusColors[day.Status]; ok {
+			fill = &color
+		}
+		doc.Rect(x, top, cellWidth, cellHeight, fill, &border)
+		doc.Text(x+4, top+11, 9, true, pdfBlack, strconv.Itoa(day.Date.Day()))
+		if label, ok := reportStatusLabels[day.Status]; ok {
+			doc.TextRight(x+cellWidth-4, top+11, 7, false, pdfGray, label)
+		}
+
+		line := top + 25
+		if record := day.Record; record != nil && record.CheckIn != nil {
+			times := record.CheckIn.In(loc).Format("15:04") + " - "
+			if record.CheckOut != nil {
+				times += record.CheckOut.In(loc).Format("15:04")
+			}
+			doc.Text(x+4, line, 8, false, pdfBlack, times)
+			line += 11
+			if record.CheckOut != nil {
+				doc.Text(x+4, line, 8, false, pdfGray, fmt.Sprintf("%.2f h", minutesToHours(record.WorkedMinutes)))
+				line += 11
+			}
+		}
+		if day.Detail != "" {
+			doc.Text(x+4, line, 7, false, pdfGray, pdfTruncate(day.Detail, cellWidth-8, 7, false))
+		}
+	}
+	weeks := (offset + len(days) + 6) / 7
+	y += float64(weeks)*cellHeight + 30
+
+	// Totals
+	doc.Text(reportMargin, y, 12, true, pdfBlack, "Summary")
+	y += 8
+	totals := []struct {
+		label, value string
+	}{
+		{"Working days", strconv.Itoa(stats.TotalDays)},
+		{"Present days", strconv.Itoa(stats.PresentDays)},
+		{"Late days", strconv.Itoa(stats.LateDays)},
+		{"Half days", strconv.Itoa(stats.HalfDayDays)},
+		{"Absent days", strconv.Itoa(stats.AbsentDays)},
+		{"Leave days", strconv.FormatFloat(stats.LeaveDays, 'f', -1, 64)},
+		{"Holidays", strconv.Itoa(stats.HolidayDays)},
+		{"Days worked on holidays", strconv.Itoa(stats.HolidayWorkDays)},
+		{"Total hours", fmt.Sprintf("%.2f", stats.TotalWorkedHours)},
+		{"Approved overtime hours", fmt.Sprintf("%.2f", stats.OvertimeHours)},
+		{"Attendance rate", fmt.Sprintf("%.1f%%", stats.AttendanceRate)},
+	}
+	half := width / 2
+	for i, total := range totals {
+		x := reportMargin + float64(i%2)*half
+		rowY := y + float64(i/2+1)*16
+		doc.Text(x, rowY, 9, false, pdfGray, total.label)
+		doc.TextRight(x+half-20, rowY, 9, true, pdfBlack, total.value)
+	}
+	y += float64((len(totals)+1)/2)*16 + 30
+
+	// Leave taken
+	doc.Text(reportMargin, y, 12, true, pdfBlack, "Leave Taken")
+	y += 20
+	if len(leaveRequests) == 0 {
+		doc.Text(reportMargin, y, 9, false, pdfGray, "No leave taken this month.")
+		return doc, nil
+	}
+
+	columns := []struct {
+		title string
+		x     float64
+	}{
+		{"Type", reportMargin}, {"From", reportMargin + 130}, {"To", reportMargin + 200},
+		{"Days", reportMargin + 270}, {"Reason", reportMargin + 310},
+	}
+	header := func() {
+		for _, column := range columns {
+			doc.Text(column.x, y, 9, true, pdfGray, column.title)
+		}
+		doc.Line(reportMargin, y+4, right, y+4, pdfGray)
+		y += 16
+	}
+	header()
+	for _, request := range leaveRequests {
+		if y > pdfPageHeight-reportMargin {
+			doc.AddPage()
+			y = 60
+			header()
+		}
+
+		// Only the part of the leave inside the month is counted
+		from, _ := parseDate(string(request.StartDate), loc)
+		to, _ := parseDate(string(request.EndDate), loc)
+		days := leaveDays(user, maxTime(from, start), minTime(to, end), request.HalfDay)
+
+		doc.Text(columns[0].x, y, 9, false, pdfBlack, pdfTruncate(request.LeaveType.Name, 125, 9, false))
+		doc.Text(columns[1].x, y, 9, false, pdfBlack, string(request.StartDate))
+		doc.Text(columns[2].x, y, 9, false, pdfBlack, string(request.EndDate))
+		doc.Text(columns[3].x, y, 9, false, pdfBlack, strconv.FormatFloat(days, 'f', -1, 64))
+		doc.Text(columns[4].x, y, 9, false, pdfBlack, pdfTruncate(request.Reason, right-columns[4].x, 9, false))
+		y += 14
+	}
+	return doc, nil
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+// writeAttendanceReport renders user's report for the requested month and
+// sends it as a PDF attachment.
+func writeAttendanceReport(c *gin.Context, user User) {
+	start, end, err := reportMonth(c, userLocation(user))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	doc, err := renderAttendanceReport(user, start, end)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate report"})
+		return
+	}
+
+	filename := fmt.Sprintf("attendance_report_%d_%s.pdf", user.ID, start.Format("2006-01"))
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Header("Content-Type", "application/pdf")
+	c.Status(http.StatusOK)
+	doc.WriteTo(c.Writer)
+}
+
+func getMyAttendanceReport(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var user User
+	if err := db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	writeAttendanceReport(c, user)
+}
+
+func getUserAttendanceReport(c *gin.Context) {
+	var user User
+	if err := db.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	writeAttendanceReport(c, user)
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseClock parses an "HH:MM" time of day.
+func parseClock(value string) (hour, minute int, err error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	return t.Hour(), t.Minute(), nil
+}
+
+// parseWorkingDays parses a comma separated list of weekday numbers
+// (0 = Sunday ... 6 = Saturday).
+func parseWorkingDays(value string) (map[time.Weekday]bool, error) {
+	days := make(map[time.Weekday]bool)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		day, err := strconv.Atoi(part)
+		if err != nil || day < 0 || day > 6 {
+			return nil, fmt.Errorf("invalid working day %q, expected 0-6", part)
+		}
+		days[time.Weekday(day)] = true
+	}
+	if len(days) == 0 {
+		return nil, errors.New("at least one working day is required")
+	}
+	return days, nil
+}
+
+func (s WorkSchedule) validate() error {
+	if _, _, err := parseClock(s.StartTime); err != nil {
+		return err
+	}
+	if _, _, err := parseClock(s.EndTime); err != nil {
+		return err
+	}
+	if s.StartTime == s.EndTime {
+		return errors.New("start and end time must differ")
+	}
+	if s.GracePeriodMinutes < 0 || s.HalfDayMinutes < 0 {
+		return errors.New("grace period and half-day threshold must not be negative")
+	}
+	if !validAutoCheckoutPolicy(s.AutoCheckoutPolicy) {
+		return errors.New("auto-checkout policy must be scheduled_end, max_hours, flag or off")
+	}
+	if s.AutoCheckoutHours < 0 {
+		return errors.New("auto-checkout hours must not be negative")
+	}
+	_, err := parseWorkingDays(s.WorkingDays)
+	return err
+}
+
+// startOn returns the scheduled start of work on the given day, in the day's location.
+func (s WorkSchedule) startOn(day time.Time) time.Time {
+	hour, minute, _ := parseClock(s.StartTime)
+	return time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, day.Location())
+}
+
+// endOn returns the scheduled end of the shift starting on the given day, in
+// the day's location. Overnight shifts end on the following day.
+func (s WorkSchedule) endOn(day time.Time) time.Time {
+	hour, minute, _ := parseClock(s.EndTime)
+	end := time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, day.Location())
+	if s.isOvernight() {
+		end = end.AddDate(0, 0, 1)
+	}
+	return end
+}
+
+// isOvernight reports whether the shift ends on the day after it starts.
+func (s WorkSchedule) isOvernight() bool {
+	startHour, startMinute, _ := parseClock(s.StartTime)
+	endHour, endMinute, _ := parseClock(s.EndTime)
+	return endHour*60+endMinute <= startHour*60+startMinute
+}
+
+// shiftDate returns the date of the shift that the given moment belongs to.
+// On overnight schedules, moments before the shift end belong to the shift
+// that started the previous day.
+func (s WorkSchedule) shiftDate(at time.Time) string {
+	if s.isOvernight() {
+		previous := at.AddDate(0, 0, -1)
+		if at.Before(s.endOn(previous)) {
+			return previous.Format(dateLayout)
+		}
+	}
+	return at.Format(dateLayout)
+}
+
+// lateAfter returns the moment after which a check-in on the given day is late.
+func (s WorkSchedule) lateAfter(day time.Time) time.Time {
+	return s.startOn(day).Add(time.Duration(s.GracePeriodMinutes) * time.Minute)
+}
+
+func (s WorkSchedule) isWorkingDay(day time.Time) bool {
+	days, err := parseWorkingDays(s.WorkingDays)
+	if err != nil {
+		return false
+	}
+	return days[day.Weekday()]
+}
+
+// scheduleFor resolves the effective schedule for a user: a personal
+// schedule wins over a department schedule, which wins over the default.
+func scheduleFor(user User) WorkSchedule {
+	var schedule WorkSchedule
+	if err := db.Where("user_id = ?", user.ID).First(&schedule).Error; err == nil {
+		return schedule
+	}
+	if user.Department != "" {
+		if err := db.Where("user_id IS NULL AND department = ?", user.Department).First(&schedule).Error; err == nil {
+			return schedule
+		}
+	}
+	return defaultSchedule
+}
+
+func getMySchedule(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var user User
+	if err := db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, scheduleFor(user))
+}
+
+func getUserSchedule(c *gin.Context) {
+	var user User
+	if err := db.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, scheduleFor(user))
+}
+
+func getSchedules(c *gin.Context) {
+	var schedules []WorkSchedule
+	if err := db.Order("department, user_id").Find(&schedules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch schedules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"default":   defaultSchedule,
+		"overrides": schedules,
+	})
+}
+
+func createSchedule(c *gin.Context) {
+	var req WorkScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var schedule WorkSchedule
+	if status, err := applyScheduleRequest(&schedule, req); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := db.Create(&schedule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create schedule"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, schedule)
+}
+
+func updateSchedule(c *gin.Context) {
+	var schedule WorkSchedule
+	if err := db.First(&schedule, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Schedule not found"})
+		return
+	}
+
+	var req WorkScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status, err := applyScheduleRequest(&schedule, req); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := db.Save(&schedule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update schedule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, schedule)
+}
+
+func deleteSchedule(c *gin.Context) {
+	var schedule WorkSchedule
+	if err := db.First(&schedule, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Schedule not found"})
+		return
+	}
+
+	if err := db.Delete(&schedule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete schedule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Schedule deleted successfully"})
+}
+
+// applyScheduleRequest copies a validated request onto schedule. It returns
+// the HTTP status to report alongside any validation error.
+func applyScheduleRequest(schedule *WorkSchedule, req WorkScheduleRequest) (int, error) {
+	if (req.UserID == nil) == (req.Department == "") {
+		return http.StatusBadRequest, errors.New("Exactly one of user_id or department is required")
+	}
+
+	schedule.Name = req.Name
+	schedule.UserID = req.UserID
+	schedule.Department = ""
+	if req.UserID == nil {
+		schedule.Department = req.Department
+	}
+	schedule.StartTime = req.StartTime
+	schedule.EndTime = req.EndTime
+	schedule.GracePeriodMinutes = defaultSchedule.GracePeriodMinutes
+	if req.GracePeriodMinutes != nil {
+		schedule.GracePeriodMinutes = *req.GracePeriodMinutes
+	}
+	schedule.HalfDayMinutes = defaultSchedule.HalfDayMinutes
+	if req.HalfDayMinutes != nil {
+		schedule.HalfDayMinutes = *req.HalfDayMinutes
+	}
+	schedule.WorkingDays = req.WorkingDays
+	if schedule.WorkingDays == "" {
+		schedule.WorkingDays = defaultSchedule.WorkingDays
+	}
+	schedule.AutoCheckoutPolicy = req.AutoCheckoutPolicy
+	schedule.AutoCheckoutHours = req.AutoCheckoutHours
+
+	if err := schedule.validate(); err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	if req.UserID != nil {
+		var user User
+		if err := db.First(&user, *req.UserID).Error; err != nil {
+			return http.StatusNotFound, errors.New("User not found")
+		}
+	}
+
+	// Only one schedule may exist per user and per department
+	query := db.Model(&WorkSchedule{}).Where("id <> ?", schedule.ID)
+	if req.UserID != nil {
+		query = query.Where("user_id = ?", *req.UserID)
+	} else {
+		query = query.Where("user_id IS NULL AND department = ?", req.Department)
+	}
+	var count int64
+	query.Count(&count)
+	if count > 0 {
+		return http.StatusConflict, errors.New("A schedule already exists for this user or department")
+	}
+
+	return http.StatusOK, nil
+}
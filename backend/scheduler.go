@@ -0,0 +1,58 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// job is a background task run periodically by the in-process scheduler.
+type job struct {
+	name     string
+	interval time.Duration
+	run      func(now time.Time)
+}
+
+// jobs lists every background task. Each job must be safe to run again for
+// work it has already done.
+var jobs = []job{
+	{name: "mark-absences", interval: time.Minute, run: markAbsences},
+	{name: "auto-checkout", interval: time.Minute, run: autoCheckout},
+	{name: "attendance-alerts", interval: time.Hour, run: checkAlertRules},
+	{name: "expire-sessions", interval: time.Hour, run: deleteExpiredSessions},
+	{name: "expire-password-resets", interval: time.Hour, run: deleteExpiredResetTokens},
+	{name: "expire-mfa-challenges", interval: time.Hour, run: deleteExpiredMFAChallenges},
+}
+
+// schedulerEnabled can be turned off when several instances share a database.
+var schedulerEnabled = true
+
+// startScheduler runs every job once and then on its interval, each in its
+// own goroutine, for the lifetime of the process.
+func startScheduler() {
+	if !schedulerEnabled {
+		log.Println("Scheduler disabled")
+		return
+	}
+
+	for _, j := range jobs {
+		go func(j job) {
+			runJob(j, time.Now())
+
+			ticker := time.NewTicker(j.interval)
+			defer ticker.Stop()
+			for now := range ticker.C {
+				runJob(j, now)
+			}
+		}(j)
+	}
+}
+
+// runJob runs a job, keeping a panic in one run from stopping the scheduler.
+func runJob(j job, now time.Time) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Job %s panicked: %v", j.name, r)
+		}
+	}()
+	j.run(now)
+}
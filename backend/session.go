@@ -0,0 +1,205 @@
+package main
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Attendance session types
+const (
+	sessionWork  = "work"
+	sessionBreak = "break"
+)
+
+// loadSessions returns the sessions of an attendance record in chronological order.
+func loadSessions(tx *gorm.DB, attendanceID uint) ([]AttendanceSession, error) {
+	var sessions []AttendanceSession
+	err := tx.Where("attendance_id = ?", attendanceID).Order("started_at, id").Find(&sessions).Error
+	return sessions, err
+}
+
+// openSession returns the session still in progress, or nil.
+func openSession(sessions []AttendanceSession) *AttendanceSession {
+	for i := len(sessions) - 1; i >= 0; i-- {
+		if sessions[i].EndedAt == nil {
+			return &sessions[i]
+		}
+	}
+	return nil
+}
+
+// startSession opens a new session of the given type at the given time.
+func startSession(tx *gorm.DB, attendanceID uint, sessionType string, at time.Time) error {
+	return tx.Create(&AttendanceSession{
+		AttendanceID: attendanceID,
+		Type:         sessionType,
+		StartedAt:    at,
+	}).Error
+}
+
+// endSession closes a running session at the given time.
+func endSession(tx *gorm.DB, session *AttendanceSession, at time.Time) error {
+	session.EndedAt = &at
+	return tx.Save(session).Error
+}
+
+// retimeSessions moves the first check-in and last check-out of an
+// attendance record. Sessions outside the new span are removed, sessions
+// crossing it are trimmed and work sessions fill any gap left at either end.
+// A nil time leaves that end as it is.
+func retimeSessions(tx *gorm.DB, attendanceID uint, checkIn, checkOut *time.Time) error {
+	sessions, err := loadSessions(tx, attendanceID)
+	if err != nil {
+		return err
+	}
+
+	var kept []AttendanceSession
+	for _, session := range sessions {
+		if (checkOut != nil && !session.StartedAt.Before(*checkOut)) ||
+			(checkIn != nil && session.EndedAt != nil && !session.EndedAt.After(*checkIn)) {
+			if err := tx.Delete(&session).Error; err != nil {
+				return err
+			}
+			continue
+		}
+		if checkIn != nil && session.StartedAt.Before(*checkIn) {
+			session.StartedAt = *checkIn
+		}
+		if checkOut != nil && (session.EndedAt == nil || session.EndedAt.After(*checkOut)) {
+			end := *checkOut
+			session.EndedAt = &end
+		}
+		kept = append(kept, session)
+	}
+
+	if len(kept) == 0 {
+		if checkIn == nil {
+			return nil
+		}
+		return tx.Create(&AttendanceSession{
+			AttendanceID: attendanceID,
+			Type:         sessionWork,
+			StartedAt:    *checkIn,
+			EndedAt:      checkOut,
+		}).Error
+	}
+
+	var gaps []AttendanceSession
+	first, last := &kept[0], &kept[len(kept)-1]
+	if checkIn != nil && first.StartedAt.After(*checkIn) {
+		if first.Type == sessionWork {
+			first.StartedAt = *checkIn
+		} else {
+			gapEnd := first.StartedAt
+			gaps = append(gaps, AttendanceSession{AttendanceID: attendanceID, Type: sessionWork, StartedAt: *checkIn, EndedAt: &gapEnd})
+		}
+	}
+	if checkOut != nil && last.EndedAt != nil && last.EndedAt.Before(*checkOut) {
+		end := *checkOut
+		if last.Type == sessionWork {
+			last.EndedAt = &end
+		} else {
+			gaps = append(gaps, AttendanceSession{AttendanceID: attendanceID, Type: sessionWork, StartedAt: *last.EndedAt, EndedAt: &end})
+		}
+	}
+
+	for i := range gaps {
+		if err := tx.Create(&gaps[i]).Error; err != nil {
+			return err
+		}
+	}
+	for i := range kept {
+		if err := tx.Save(&kept[i]).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// summarizeSessions derives the first check-in, last check-out and the
+// worked and break totals of an attendance record from its sessions.
+func summarizeSessions(attendance *Attendance, sessions []AttendanceSession) {
+	attendance.CheckIn = nil
+	attendance.CheckOut = nil
+	attendance.WorkedMinutes = 0
+	attendance.BreakMinutes = 0
+
+	var worked, breaks time.Duration
+	for i := range sessions {
+		session := sessions[i]
+		if attendance.CheckIn == nil || session.StartedAt.Before(*attendance.CheckIn) {
+			attendance.CheckIn = &session.StartedAt
+		}
+		if session.EndedAt == nil {
+			continue
+		}
+		if session.Type == sessionBreak {
+			breaks += session.EndedAt.Sub(session.StartedAt)
+		} else {
+			worked += session.EndedAt.Sub(session.StartedAt)
+		}
+		if attendance.CheckOut == nil || session.EndedAt.After(*attendance.CheckOut) {
+			attendance.CheckOut = session.EndedAt
+		}
+	}
+
+	// Still checked in while any session is running
+	if openSession(sessions) != nil {
+		attendance.CheckOut = nil
+	}
+
+	attendance.WorkedMinutes = int(worked / time.Minute)
+	attendance.BreakMinutes = int(breaks / time.Minute)
+}
+
+// deriveStatus decides the status of an attendance record from its check-in
+// time and worked minutes. Lateness takes precedence over a half day.
+func deriveStatus(attendance Attendance, schedule WorkSchedule, loc *time.Location) string {
+	if attendance.CheckIn == nil {
+		return "absent"
+	}
+
+	day, err := parseDate(string(attendance.Date), loc)
+	if err != nil {
+		day = attendance.CheckIn.In(loc)
+	}
+	if attendance.CheckIn.After(schedule.lateAfter(day)) {
+		return "late"
+	}
+
+	if attendance.CheckOut != nil && attendance.WorkedMinutes < schedule.HalfDayMinutes {
+		return "half_day"
+	}
+	return "present"
+}
+
+// recomputeAttendance refreshes the derived fields of an attendance record
+// from its sessions, saves it and recalculates overtime for its week.
+func recomputeAttendance(tx *gorm.DB, attendance *Attendance, user User) error {
+	sessions, err := loadSessions(tx, attendance.ID)
+	if err != nil {
+		return err
+	}
+
+	summarizeSessions(attendance, sessions)
+	attendance.Status = deriveStatus(*attendance, scheduleFor(user), userLocation(user))
+	attendance.HolidayWork = attendance.CheckIn != nil && isHoliday(tx, user, string(attendance.Date))
+
+	if err := tx.Omit(clause.Associations).Save(attendance).Error; err != nil {
+		return err
+	}
+	return recomputeOvertime(tx, user, string(attendance.Date), attendance)
+}
+
+// appendNote adds a note to existing attendance notes.
+func appendNote(notes, note string) string {
+	if note == "" {
+		return notes
+	}
+	if notes == "" {
+		return note
+	}
+	return notes + " | " + note
+}
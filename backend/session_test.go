@@ -0,0 +1,160 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// testTime parses an RFC 3339 timestamp and returns a pointer to it.
+func testTime(t *testing.T, value string) *time.Time {
+	t.Helper()
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t.Fatalf("bad test time %q: %v", value, err)
+	}
+	return &parsed
+}
+
+func TestSummarizeSessions(t *testing.T) {
+	session := func(sessionType, start, end string) AttendanceSession {
+		s := AttendanceSession{Type: sessionType, StartedAt: *testTime(t, start)}
+		if end != "" {
+			s.EndedAt = testTime(t, end)
+		}
+		return s
+	}
+
+	tests := []struct {
+		name          string
+		sessions      []AttendanceSession
+		checkIn       string
+		checkOut      string
+		workedMinutes int
+		breakMinutes  int
+	}{
+		{
+			name: "no sessions",
+		},
+		{
+			name: "work with a break",
+			sessions: []AttendanceSession{
+				session(sessionWork, "2026-10-14T09:00:00Z", "2026-10-14T12:00:00Z"),
+				session(sessionBreak, "2026-10-14T12:00:00Z", "2026-10-14T12:30:00Z"),
+				session(sessionWork, "2026-10-14T12:30:00Z", "2026-10-14T17:00:00Z"),
+			},
+			checkIn:       "2026-10-14T09:00:00Z",
+			checkOut:      "2026-10-14T17:00:00Z",
+			workedMinutes: 450,
+			breakMinutes:  30,
+		},
+		{
+			name: "still working",
+			sessions: []AttendanceSession{
+				session(sessionWork, "2026-10-14T09:00:00Z", "2026-10-14T12:00:00Z"),
+				session(sessionWork, "2026-10-14T13:00:00Z", ""),
+			},
+			checkIn:       "2026-10-14T09:00:00Z",
+			workedMinutes: 180,
+		},
+		{
+			name: "on a break",
+			sessions: []AttendanceSession{
+				session(sessionWork, "2026-10-14T09:00:00Z", "2026-10-14T12:00:00Z"),
+				session(sessionBreak, "2026-10-14T12:00:00Z", ""),
+			},
+			checkIn:       "2026-10-14T09:00:00Z",
+			workedMinutes: 180,
+		},
+		{
+			name: "out of order",
+			sessions: []AttendanceSession{
+				session(sessionWork, "2026-10-14T13:00:00Z", "2026-10-14T17:00:00Z"),
+				session(sessionWork, "2026-10-14T08:00:00Z", "2026-10-14T12:00:00Z"),
+			},
+			checkIn:       "2026-10-14T08:00:00Z",
+			checkOut:      "2026-10-14T17:00:00Z",
+			workedMinutes: 480,
+		},
+		{
+			name: "partial minutes are dropped",
+			sessions: []AttendanceSession{
+				session(sessionWork, "2026-10-14T09:00:00Z", "2026-10-14T09:01:59Z"),
+			},
+			checkIn:       "2026-10-14T09:00:00Z",
+			checkOut:      "2026-10-14T09:01:59Z",
+			workedMinutes: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Leftovers from an earlier summary must not survive
+			stale := testTime(t, "2026-01-01T00:00:00Z")
+			attendance := Attendance{CheckIn: stale, CheckOut: stale, WorkedMinutes: 99, BreakMinutes: 99}
+			summarizeSessions(&attendance, tt.sessions)
+
+			if got := formatTestTime(attendance.CheckIn); got != tt.checkIn {
+				t.Errorf("check-in = %q, want %q", got, tt.checkIn)
+			}
+			if got := formatTestTime(attendance.CheckOut); got != tt.checkOut {
+				t.Errorf("check-out = %q, want %q", got, tt.checkOut)
+			}
+			if attendance.WorkedMinutes != tt.workedMinutes {
+				t.Errorf("worked minutes = %d, want %d", attendance.WorkedMinutes, tt.workedMinutes)
+			}
+			if attendance.BreakMinutes != tt.breakMinutes {
+				t.Errorf("break minutes = %d, want %d", attendance.BreakMinutes, tt.breakMinutes)
+			}
+		})
+	}
+}
+
+func formatTestTime(value *time.Time) string {
+	if value == nil {
+		return ""
+	}
+	return value.UTC().Format(time.RFC3339)
+}
+
+func TestDeriveStatus(t *testing.T) {
+	schedule := WorkSchedule{StartTime: "09:00", EndTime: "17:00", GracePeriodMinutes: 15, HalfDayMinutes: 240}
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		loc           *time.Location
+		checkIn       string
+		checkOut      string
+		workedMinutes int
+		want          string
+	}{
+		{name: "no check-in", loc: time.UTC, want: "absent"},
+		{name: "on time", loc: time.UTC, checkIn: "2026-10-14T08:55:00Z", checkOut: "2026-10-14T17:00:00Z", workedMinutes: 485, want: "present"},
+		{name: "within grace", loc: time.UTC, checkIn: "2026-10-14T09:15:00Z", checkOut: "2026-10-14T17:00:00Z", workedMinutes: 465, want: "present"},
+		{name: "after grace", loc: time.UTC, checkIn: "2026-10-14T09:15:01Z", checkOut: "2026-10-14T17:00:00Z", workedMinutes: 464, want: "late"},
+		{name: "short day", loc: time.UTC, checkIn: "2026-10-14T09:00:00Z", checkOut: "2026-10-14T12:00:00Z", workedMinutes: 180, want: "half_day"},
+		{name: "exactly half a day", loc: time.UTC, checkIn: "2026-10-14T09:00:00Z", checkOut: "2026-10-14T13:00:00Z", workedMinutes: 240, want: "present"},
+		{name: "late wins over half day", loc: time.UTC, checkIn: "2026-10-14T13:00:00Z", checkOut: "2026-10-14T15:00:00Z", workedMinutes: 120, want: "late"},
+		{name: "still checked in", loc: time.UTC, checkIn: "2026-10-14T09:00:00Z", want: "present"},
+		{name: "on time in the user's timezone", loc: newYork, checkIn: "2026-10-14T13:10:00Z", checkOut: "2026-10-14T21:00:00Z", workedMinutes: 470, want: "present"},
+		{name: "late in the user's timezone", loc: newYork, checkIn: "2026-10-14T13:20:00Z", checkOut: "2026-10-14T21:00:00Z", workedMinutes: 460, want: "late"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attendance := Attendance{Date: "2026-10-14", WorkedMinutes: tt.workedMinutes}
+			if tt.checkIn != "" {
+				attendance.CheckIn = testTime(t, tt.checkIn)
+			}
+			if tt.checkOut != "" {
+				attendance.CheckOut = testTime(t, tt.checkOut)
+			}
+			if got := deriveStatus(attendance, schedule, tt.loc); got != tt.want {
+				t.Errorf("deriveStatus() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
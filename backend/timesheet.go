@@ -0,0 +1,483 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Timesheet periods
+const (
+	timesheetWeekly  = "weekly"
+	timesheetMonthly = "monthly"
+)
+
+// timesheetPeriod is the length of every timesheet in the organization.
+var timesheetPeriod = timesheetWeekly
+
+var errPeriodLocked = errors.New("Attendance in this period is locked by an approved timesheet")
+
+// timesheetRange returns the first and last day of the timesheet period
+// containing day. Weekly periods run from Monday to Sunday.
+func timesheetRange(day time.Time) (time.Time, time.Time) {
+	if timesheetPeriod == timesheetMonthly {
+		start := time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, day.Location())
+		return start, start.AddDate(0, 1, -1)
+	}
+	start := weekStart(day)
+	return start, start.AddDate(0, 0, 6)
+}
+
+// isPeriodLocked reports whether an approved timesheet of the user covers any
+// day from start to end (inclusive YYYY-MM-DD dates).
+func isPeriodLocked(userID uint, start, end string) bool {
+	var count int64
+	err := db.Model(&Timesheet{}).
+		Where("user_id = ? AND status = ? AND start_date <= ? AND end_date >= ?", userID, "approved", end, start).
+		Count(&count).Error
+	return err == nil && count > 0
+}
+
+// refreshTimesheet recalculates the totals of a timesheet and returns the
+// attendance and approved leave it covers.
+func refreshTimesheet(timesheet *Timesheet, user User) ([]Attendance, []LeaveRequest, error) {
+	startKey, endKey := string(timesheet.StartDate), string(timesheet.EndDate)
+
+	var attendances []Attendance
+	if err := db.Preload("Sessions", func(tx *gorm.DB) *gorm.DB { return tx.Order("started_at, id") }).
+		Where("user_id = ? AND date BETWEEN ? AND ?", user.ID, startKey, endKey).
+		Order("date, id").
+		Find(&attendances).Error; err != nil {
+		return nil, nil, err
+	}
+
+	var leave []LeaveRequest
+	if err := db.Preload("LeaveType").
+		Where("user_id = ? AND status = ? AND start_date <= ? AND end_date >= ?", user.ID, "approved", endKey, startKey).
+		Order("start_date").
+		Find(&leave).Error; err != nil {
+		return nil, nil, err
+	}
+
+	loc := userLocation(user)
+	start, err := parseDate(startKey, loc)
+	if err != nil {
+		return nil, nil, err
+	}
+	end, err := parseDate(endKey, loc)
+	if err != nil {
+		return nil, nil, err
+	}
+	leaveByDate, err := approvedLeaveByDate(db, user, start, end)
+	if err != nil {
+		return nil, nil, err
+	}
+	holidays, err := holidaysFor(db, user, startKey, endKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	timesheet.WorkedMinutes = 0
+	timesheet.ScheduledMinutes = 0
+	timesheet.OvertimeMinutes = 0
+	timesheet.LeaveDays = 0
+	timesheet.AbsentDays = 0
+
+	schedule := scheduleFor(user)
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		key := d.Format(dateLayout)
+		_, holiday := holidays[key]
+		timesheet.ScheduledMinutes += scheduledMinutes(schedule, d, holiday, leaveByDate[key])
+		timesheet.LeaveDays += leaveByDate[key]
+	}
+	for _, attendance := range attendances {
+		timesheet.WorkedMinutes += attendance.WorkedMinutes
+		if attendance.OvertimeStatus == "approved" {
+			timesheet.OvertimeMinutes += attendance.OvertimeMinutes
+		}
+		if attendance.Status == "absent" {
+			timesheet.AbsentDays++
+		}
+	}
+
+	return attendances, leave, nil
+}
+
+// timesheetDetail refreshes a timesheet that can still change and returns it
+// with the records it covers. Approved timesheets keep the totals they were
+// approved with.
+func timesheetDetail(timesheet Timesheet, user User) (TimesheetDetail, error) {
+	refreshed := timesheet
+	attendances, leave, err := refreshTimesheet(&refreshed, user)
+	if err != nil {
+		return TimesheetDetail{}, err
+	}
+
+	if timesheet.Status == "draft" || timesheet.Status == "rejected" {
+		timesheet = refreshed
+		if err := saveTimesheetTotals(db, &timesheet); err != nil {
+			return TimesheetDetail{}, err
+		}
+	}
+
+	return TimesheetDetail{Timesheet: timesheet, Attendance: attendances, Leave: leave}, nil
+}
+
+func saveTimesheetTotals(tx *gorm.DB, timesheet *Timesheet) error {
+	return tx.Model(timesheet).
+		Select("worked_minutes", "scheduled_minutes", "overtime_minutes", "leave_days", "absent_days").
+		Updates(timesheet).Error
+}
+
+// getCurrentTimesheet returns the user's timesheet for the period containing
+// the date query parameter, today by default, creating it if needed.
+func getCurrentTimesheet(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var user User
+	if err := db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	loc := userLocation(user)
+	today := time.Now().In(loc).Format(dateLayout)
+	day, err := parseDate(c.DefaultQuery("date", today), loc)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date, expected YYYY-MM-DD"})
+		return
+	}
+
+	start, end := timesheetRange(day)
+	if start.Format(dateLayout) > today {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Timesheets cannot be created for future periods"})
+		return
+	}
+
+	timesheet := Timesheet{
+		UserID:    user.ID,
+		Period:    timesheetPeriod,
+		StartDate: Date(start.Format(dateLayout)),
+		EndDate:   Date(end.Format(dateLayout)),
+		Status:    "draft",
+	}
+	if err := db.Where("user_id = ? AND start_date = ?", user.ID, timesheet.StartDate).
+		FirstOrCreate(&timesheet).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create timesheet"})
+		return
+	}
+
+	timesheet.User = user
+	detail, err := timesheetDetail(timesheet, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load timesheet"})
+		return
+	}
+
+	c.JSON(http.StatusOK, detail)
+}
+
+func getMyTimesheets(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	page, limit := parsePagination(c)
+	offset := (page - 1) * limit
+
+	query := db.Model(&Timesheet{}).Where("user_id = ?", userID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var timesheets []Timesheet
+	if err := query.Order("start_date DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&timesheets).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch timesheets"})
+		return
+	}
+
+	c.JSON(http.StatusOK, paginated(timesheets, page, limit, total))
+}
+
+func getMyTimesheet(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var timesheet Timesheet
+	if err := db.Preload("User").Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&timesheet).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Timesheet not found"})
+		return
+	}
+
+	detail, err := timesheetDetail(timesheet, timesheet.User)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load timesheet"})
+		return
+	}
+
+	c.JSON(http.StatusOK, detail)
+}
+
+// submitTimesheet sends a draft or rejected timesheet for approval. Shifts
+// still open or flagged for review must be resolved first.
+func submitTimesheet(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var timesheet Timesheet
+	if err := db.Preload("User").Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&timesheet).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Timesheet not found"})
+		return
+	}
+
+	if timesheet.Status != "draft" && timesheet.Status != "rejected" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only draft or rejected timesheets can be submitted"})
+		return
+	}
+
+	// Approval locks the period, so it has to be over first
+	if today := time.Now().In(userLocation(timesheet.User)).Format(dateLayout); today <= string(timesheet.EndDate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("The period can be submitted once it ends on %s", timesheet.EndDate)})
+		return
+	}
+
+	attendances, _, err := refreshTimesheet(&timesheet, timesheet.User)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load timesheet"})
+		return
+	}
+	for _, attendance := range attendances {
+		if attendance.CheckIn != nil && attendance.CheckOut == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Check out of the shift on %s before submitting", attendance.Date)})
+			return
+		}
+		if attendance.NeedsReview {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("The shift on %s needs a correction before submitting", attendance.Date)})
+			return
+		}
+	}
+
+	now := time.Now()
+	timesheet.Status = "submitted"
+	timesheet.SubmittedAt = &now
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := saveTimesheetTotals(tx, &timesheet); err != nil {
+			return err
+		}
+		return tx.Model(&timesheet).Select("status", "submitted_at").Updates(&timesheet).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit timesheet"})
+		return
+	}
+
+	c.JSON(http.StatusOK, timesheet)
+}
+
+// managedUserIDs selects the users whose timesheets the reviewer may see:
+// everyone for admins, their own department for managers. Managers without
+// a department see no one.
+func managedUserIDs(reviewer User) *gorm.DB {
+	query := db.Model(&User{}).Select("id")
+	if reviewer.Role != "admin" {
+		query = query.Where("department = ? AND department <> ''", reviewer.Department)
+	}
+	return query
+}
+
+// loadManagedTimesheet loads the timesheet in the id parameter if the
+// current manager or admin may review it, writing an error response if not.
+func loadManagedTimesheet(c *gin.Context) (Timesheet, User, bool) {
+	reviewerID, _ := c.Get("user_id")
+
+	var reviewer User
+	if err := db.First(&reviewer, reviewerID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return Timesheet{}, User{}, false
+	}
+
+	var timesheet Timesheet
+	if err := db.Preload("User").
+		Where("id = ? AND user_id IN (?)", c.Param("id"), managedUserIDs(reviewer)).
+		First(&timesheet).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Timesheet not found"})
+		return Timesheet{}, User{}, false
+	}
+
+	return timesheet, reviewer, true
+}
+
+func getTeamTimesheets(c *gin.Context) {
+	reviewerID, _ := c.Get("user_id")
+
+	var reviewer User
+	if err := db.First(&reviewer, reviewerID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	page, limit := parsePagination(c)
+	offset := (page - 1) * limit
+
+	query := db.Model(&Timesheet{}).Where("user_id IN (?)", managedUserIDs(reviewer))
+
+	// Apply filters
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if userIDParam := c.Query("user_id"); userIDParam != "" {
+		if userID, err := strconv.ParseUint(userIDParam, 10, 32); err == nil {
+			query = query.Where("user_id = ?", userID)
+		}
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var timesheets []Timesheet
+	if err := query.Preload("User").
+		Order("start_date DESC, user_id").
+		Limit(limit).
+		Offset(offset).
+		Find(&timesheets).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch timesheets"})
+		return
+	}
+
+	c.JSON(http.StatusOK, paginated(timesheets, page, limit, total))
+}
+
+func getTeamTimesheet(c *gin.Context) {
+	timesheet, _, ok := loadManagedTimesheet(c)
+	if !ok {
+		return
+	}
+
+	detail, err := timesheetDetail(timesheet, timesheet.User)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load timesheet"})
+		return
+	}
+
+	c.JSON(http.StatusOK, detail)
+}
+
+func approveTimesheet(c *gin.Context) {
+	reviewTimesheet(c, "approved")
+}
+
+func rejectTimesheet(c *gin.Context) {
+	reviewTimesheet(c, "rejected")
+}
+
+// reviewTimesheet approves or rejects a submitted timesheet. Approval takes
+// the final totals and locks the period.
+func reviewTimesheet(c *gin.Context, status string) {
+	var req ReviewRequest
+	if err := bindOptionalJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	timesheet, reviewer, ok := loadManagedTimesheet(c)
+	if !ok {
+		return
+	}
+
+	if timesheet.UserID == reviewer.ID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot review your own timesheet"})
+		return
+	}
+	if timesheet.Status != "submitted" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only submitted timesheets can be reviewed"})
+		return
+	}
+
+	if _, _, err := refreshTimesheet(&timesheet, timesheet.User); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load timesheet"})
+		return
+	}
+
+	now := time.Now()
+	timesheet.Status = status
+	timesheet.ReviewedBy = &reviewer.ID
+	timesheet.ReviewedAt = &now
+	timesheet.ReviewNote = req.Note
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := saveTimesheetTotals(tx, &timesheet); err != nil {
+			return err
+		}
+		if err := tx.Model(&timesheet).
+			Select("status", "reviewed_by", "reviewed_at", "review_note").
+			Updates(&timesheet).Error; err != nil {
+			return err
+		}
+
+		message := fmt.Sprintf("Your timesheet for %s to %s was %s.", timesheet.StartDate, timesheet.EndDate, status)
+		if req.Note != "" {
+			message += " " + req.Note
+		}
+		return notify(tx, timesheet.UserID, "timesheet_reviewed", "Timesheet "+status, message, nil)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to review timesheet"})
+		return
+	}
+
+	c.JSON(http.StatusOK, timesheet)
+}
+
+// reopenTimesheet returns an approved timesheet to draft so attendance in
+// its period can be changed again.
+func reopenTimesheet(c *gin.Context) {
+	adminID, _ := c.Get("user_id")
+
+	var req ReviewRequest
+	if err := bindOptionalJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var timesheet Timesheet
+	if err := db.First(&timesheet, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Timesheet not found"})
+		return
+	}
+
+	if timesheet.Status != "approved" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only approved timesheets can be reopened"})
+		return
+	}
+
+	now := time.Now()
+	admin := adminID.(uint)
+	timesheet.Status = "draft"
+	timesheet.ReopenedBy = &admin
+	timesheet.ReopenedAt = &now
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&timesheet).Select("status", "reopened_by", "reopened_at").Updates(&timesheet).Error; err != nil {
+			return err
+		}
+
+		message := fmt.Sprintf("Your timesheet for %s to %s was reopened and must be submitted again.", timesheet.StartDate, timesheet.EndDate)
+		if req.Note != "" {
+			message += " " + req.Note
+		}
+		return notify(tx, timesheet.UserID, "timesheet_reviewed", "Timesheet reopened", message, nil)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reopen timesheet"})
+		return
+	}
+
+	c.JSON(http.StatusOK, timesheet)
+}
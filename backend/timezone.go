@@ -0,0 +1,72 @@
+package main
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// dateLayout is the format of calendar dates such as Attendance.Date.
+const dateLayout = "2006-01-02"
+
+// Date is a calendar date without a time of day, stored as YYYY-MM-DD.
+type Date string
+
+// Scan accepts both text dates and the time.Time values the SQLite driver
+// produces for columns declared as date.
+func (d *Date) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*d = ""
+	case time.Time:
+		*d = Date(v.Format(dateLayout))
+	case string:
+		*d = Date(truncateDate(v))
+	case []byte:
+		*d = Date(truncateDate(string(v)))
+	default:
+		return fmt.Errorf("cannot scan %T into Date", value)
+	}
+	return nil
+}
+
+func (d Date) Value() (driver.Value, error) {
+	return string(d), nil
+}
+
+func truncateDate(value string) string {
+	if len(value) > len(dateLayout) {
+		return value[:len(dateLayout)]
+	}
+	return value
+}
+
+// defaultLocation is the organization timezone, used for users without one.
+var defaultLocation = time.UTC
+
+// validateTimezone reports whether name is a loadable IANA timezone.
+func validateTimezone(name string) error {
+	if name == "" {
+		return nil
+	}
+	if _, err := time.LoadLocation(name); err != nil {
+		return errors.New("Invalid timezone: " + name)
+	}
+	return nil
+}
+
+// userLocation returns the timezone attendance for user is bucketed in.
+func userLocation(user User) *time.Location {
+	if user.Timezone != "" {
+		if loc, err := time.LoadLocation(user.Timezone); err == nil {
+			return loc
+		}
+	}
+	return defaultLocation
+}
+
+// parseDate parses a YYYY-MM-DD date as midnight in loc.
+func parseDate(value string, loc *time.Location) (time.Time, error) {
+	return time.ParseInLocation(dateLayout, value, loc)
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Lifetimes of access and refresh tokens. Access tokens are short so that a
+// revoked session stops working quickly even for clients that cache them.
+var (
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 30 * 24 * time.Hour
+)
+
+var errInvalidRefreshToken = errors.New("Invalid or expired refresh token")
+
+// newSecretToken returns a random token to hand out and the hash to store.
+func newSecretToken() (string, string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+	return token, hashToken(token), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// issueRefreshToken adds a new token to session's chain and extends the
+// session to the token's expiry.
+func issueRefreshToken(tx *gorm.DB, session *UserSession, now time.Time) (string, error) {
+	token, hash, err := newSecretToken()
+	if err != nil {
+		return "", err
+	}
+
+	session.LastUsedAt = now
+	session.ExpiresAt = now.Add(refreshTokenTTL)
+	if err := tx.Save(session).Error; err != nil {
+		return "", err
+	}
+	if err := tx.Create(&RefreshToken{SessionID: session.ID, TokenHash: hash, ExpiresAt: session.ExpiresAt}).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// openUserSession opens a session for user on the requesting device and
+// returns the response for a successful login.
+func openUserSession(c *gin.Context, user User) (AuthResponse, error) {
+	now := time.Now()
+	session := UserSession{
+		UserID:    user.ID,
+		UserAgent: c.Request.UserAgent(),
+		IPAddress: c.ClientIP(),
+	}
+
+	var refreshToken string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		refreshToken, err = issueRefreshToken(tx, &session, now)
+		return err
+	})
+	if err != nil {
+		return AuthResponse{}, err
+	}
+
+	token, expiresAt, err := generateToken(user, session.ID)
+	if err != nil {
+		return AuthResponse{}, err
+	}
+	return AuthResponse{Token: token, ExpiresAt: expiresAt, RefreshToken: refreshToken, User: user}, nil
+}
+
+// invalidateTokens makes every access token issued to user so far invalid.
+// Sessions stay open, so clients can refresh to a token that reflects the
+// user's current account.
+func invalidateTokens(tx *gorm.DB, userID uint) error {
+	return tx.Model(&User{}).Where("id = ?", userID).
+		Update("token_version", gorm.Expr("token_version + 1")).Error
+}
+
+// revokeSessions ends every open session of user and returns how many
+// there were.
+func revokeSessions(tx *gorm.DB, userID uint) (int64, error) {
+	result := tx.Model(&UserSession{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now())
+	return result.RowsAffected, result.Error
+}
+
+// sessionActive reports whether the session an access token was issued for
+// is still open.
+func sessionActive(sessionID uint) bool {
+	var session UserSession
+	if err := db.First(&session, sessionID).Error; err != nil {
+		return false
+	}
+	return session.RevokedAt == nil && time.Now().Before(session.ExpiresAt)
+}
+
+// refreshSession exchanges a refresh token for a new access token and a new
+// refresh token. The old refresh token cannot be used again.
+func refreshSession(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	var user User
+	var session UserSession
+	var refreshToken string
+	reused := false
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var stored RefreshToken
+		if err := tx.Where("token_hash = ?", hashToken(req.RefreshToken)).First(&stored).Error; err != nil {
+			return errInvalidRefreshToken
+		}
+		if err := tx.First(&session, stored.SessionID).Error; err != nil {
+			return errInvalidRefreshToken
+		}
+		if session.RevokedAt != nil || now.After(stored.ExpiresAt) {
+			return errInvalidRefreshToken
+		}
+
+		// Claim the token; if it was already used, a copy of it is being
+		// replayed, and the session can no longer be trusted
+		result := tx.Model(&RefreshToken{}).
+			Where("id = ? AND used_at IS NULL", stored.ID).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			reused = true
+			return errInvalidRefreshToken
+		}
+
+		// Deleted users cannot refresh
+		if err := tx.First(&user, session.UserID).Error; err != nil {
+			return errInvalidRefreshToken
+		}
+
+		var err error
+		refreshToken, err = issueRefreshToken(tx, &session, now)
+		return err
+	})
+	if reused {
+		if err := db.Model(&UserSession{}).Where("id = ?", session.ID).Update("revoked_at", now).Error; err != nil {
+			log.Printf("Failed to revoke session %d after refresh token reuse: %v", session.ID, err)
+		}
+	}
+	if errors.Is(err, errInvalidRefreshToken) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh session"})
+		return
+	}
+
+	token, expiresAt, err := generateToken(user, session.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, AuthResponse{Token: token, ExpiresAt: expiresAt, RefreshToken: refreshToken, User: user})
+}
+
+// logout ends the session of a refresh token. It succeeds for tokens that
+// are unknown or already revoked, so clients can always clear their state.
+func logout(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var stored RefreshToken
+	if err := db.Where("token_hash = ?", hashToken(req.RefreshToken)).First(&stored).Error; err == nil {
+		if err := db.Model(&UserSession{}).
+			Where("id = ? AND revoked_at IS NULL", stored.SessionID).
+			Update("revoked_at", time.Now()).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// getUserSessions lists a user's sessions that have not expired.
+func getUserSessions(c *gin.Context) {
+	var sessions []UserSession
+	if err := db.Where("user_id = ? AND expires_at > ?", c.Param("id"), time.Now()).
+		Order("last_used_at DESC").
+		Find(&sessions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+// revokeUserSessions signs a user out everywhere. Their access tokens stop
+// working immediately and their refresh tokens can no longer be used.
+func revokeUserSessions(c *gin.Context) {
+	var user User
+	if err := db.Unscoped().First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	revoked, err := revokeSessions(db, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"revoked": revoked})
+}
+
+// deleteExpiredSessions removes sessions and refresh tokens past their
+// expiry, which can no longer be used.
+func deleteExpiredSessions(now time.Time) {
+	if err := db.Where("expires_at < ?", now).Delete(&RefreshToken{}).Error; err != nil {
+		log.Printf("expire-sessions: failed to delete refresh tokens: %v", err)
+		return
+	}
+	if err := db.Where("expires_at < ?", now).Delete(&UserSession{}).Error; err != nil {
+		log.Printf("expire-sessions: failed to delete sessions: %v", err)
+	}
+}
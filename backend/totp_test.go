@@ -0,0 +1,89 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 key of the RFC 6238 test vectors,
+// "12345678901234567890", base32 encoded.
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	key := []byte("12345678901234567890")
+
+	// RFC 6238 appendix B, cut to six digits
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		if got := totpCode(key, uint64(tt.unix/totpPeriod)); got != tt.want {
+			t.Errorf("totpCode at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestMatchTOTP(t *testing.T) {
+	// 287082 belongs to step 1, the 30 seconds from 30 to 59
+	const code = "287082"
+
+	tests := []struct {
+		name     string
+		secret   string
+		code     string
+		unix     int64
+		wantStep int64
+		wantOK   bool
+	}{
+		{name: "current step", secret: rfc6238Secret, code: code, unix: 59, wantStep: 1, wantOK: true},
+		{name: "start of its step", secret: rfc6238Secret, code: code, unix: 30, wantStep: 1, wantOK: true},
+		{name: "one step early", secret: rfc6238Secret, code: code, unix: 0, wantStep: 1, wantOK: true},
+		{name: "one step late", secret: rfc6238Secret, code: code, unix: 89, wantStep: 1, wantOK: true},
+		{name: "two steps late", secret: rfc6238Secret, code: code, unix: 90},
+		{name: "lower-case secret", secret: strings.ToLower(rfc6238Secret), code: code, unix: 59, wantStep: 1, wantOK: true},
+		{name: "wrong code", secret: rfc6238Secret, code: "287083", unix: 59},
+		{name: "too short", secret: rfc6238Secret, code: "28708", unix: 59},
+		{name: "too long", secret: rfc6238Secret, code: "2870820", unix: 59},
+		{name: "empty", secret: rfc6238Secret, code: "", unix: 59},
+		{name: "invalid secret", secret: "not base32!", code: code, unix: 59},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := matchTOTP(tt.secret, tt.code, time.Unix(tt.unix, 0))
+			if ok != tt.wantOK || step != tt.wantStep {
+				t.Errorf("matchTOTP() = %d, %t, want %d, %t", step, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestNewTOTPSecret(t *testing.T) {
+	secret, err := newTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil {
+		t.Fatalf("secret %q is not base32: %v", secret, err)
+	}
+	if len(key) != 20 {
+		t.Errorf("key is %d bytes, want 20", len(key))
+	}
+
+	// A fresh secret must verify its own current code
+	now := time.Now()
+	if _, ok := matchTOTP(secret, totpCode(key, uint64(now.Unix()/totpPeriod)), now); !ok {
+		t.Error("current code of a new secret does not match")
+	}
+}
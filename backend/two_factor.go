@@ -0,0 +1,470 @@
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// mfaChallengeTTL is how long a login waits for the second factor, and
+// mfaMaxAttempts how many codes it accepts before the password has to be
+// entered again.
+var (
+	mfaChallengeTTL = 5 * time.Minute
+	mfaMaxAttempts  = 5
+)
+
+// recoveryCodeCount is how many recovery codes a user gets at a time.
+const recoveryCodeCount = 10
+
+// requireAdminTwoFactor seeds SecuritySettings when the row is first
+// created; afterwards admins change it through the API.
+var requireAdminTwoFactor = false
+
+var errInvalidMFAToken = errors.New("Invalid or expired login, sign in again")
+
+// securitySettings returns the organization's security settings, creating
+// them from the configured defaults on first use.
+func securitySettings() (SecuritySettings, error) {
+	var settings SecuritySettings
+	err := db.Attrs(SecuritySettings{RequireAdminTwoFactor: requireAdminTwoFactor}).
+		FirstOrCreate(&settings, SecuritySettings{ID: 1}).Error
+	return settings, err
+}
+
+// normalizeCode strips the spaces and dashes people type into codes.
+func normalizeCode(code string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(code))
+}
+
+// newRecoveryCodes replaces user's recovery codes and returns the new ones,
+// formatted as xxxxx-xxxxx. They are only ever shown this once.
+func newRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	const alphabet = "abcdefghijkmnpqrstuvwxyz23456789" // no 0/o or 1/l
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		raw := make([]byte, 10)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+		for j, b := range raw {
+			raw[j] = alphabet[int(b)%len(alphabet)]
+		}
+		codes[i] = string(raw[:5]) + "-" + string(raw[5:])
+		if err := tx.Create(&RecoveryCode{UserID: userID, CodeHash: hashToken(normalizeCode(codes[i]))}).Error; err != nil {
+			return nil, err
+		}
+	}
+	return codes, nil
+}
+
+// verifySecondFactor checks a TOTP code or, failing that, a recovery code of
+// user, and uses it up: a TOTP code cannot be replayed and a recovery code
+// works once.
+func verifySecondFactor(user User, code string) (bool, error) {
+	code = normalizeCode(code)
+
+	if step, ok := matchTOTP(user.TOTPSecret, code, time.Now()); ok {
+		result := db.Model(&User{}).
+			Where("id = ? AND totp_last_step < ?", user.ID, step).
+			Update("totp_last_step", step)
+		return result.RowsAffected == 1, result.Error
+	}
+
+	result := db.Model(&RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, hashToken(code)).
+		Update("used_at", time.Now())
+	return result.RowsAffected == 1, result.Error
+}
+
+// openMFAChallenge starts the second step of user's login and returns the
+// response telling the client to send a code.
+func openMFAChallenge(user User) (MFAChallengeResponse, error) {
+	token, hash, err := newSecretToken()
+	if err != nil {
+		return MFAChallengeResponse{}, err
+	}
+	challenge := MFAChallenge{UserID: user.ID, TokenHash: hash, ExpiresAt: time.Now().Add(mfaChallengeTTL)}
+	if err := db.Create(&challenge).Error; err != nil {
+		return MFAChallengeResponse{}, err
+	}
+	return MFAChallengeResponse{MFARequired: true, MFAToken: token, ExpiresAt: challenge.ExpiresAt}, nil
+}
+
+// verifyLogin completes a two-factor login with the token from login and a
+// TOTP or recovery code.
+func verifyLogin(c *gin.Context) {
+	var req LoginVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var challenge MFAChallenge
+	if err := db.Where("token_hash = ? AND expires_at > ?", hashToken(req.MFAToken), time.Now()).
+		First(&challenge).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": errInvalidMFAToken.Error()})
+		return
+	}
+
+	// Count the attempt before checking it, so that concurrent guesses
+	// cannot exceed the limit
+	result := db.Model(&MFAChallenge{}).
+		Where("id = ? AND attempts < ?", challenge.ID, mfaMaxAttempts).
+		Update("attempts", gorm.Expr("attempts + 1"))
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		db.Delete(&challenge)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Too many attempts, sign in again"})
+		return
+	}
+
+	var user User
+	if err := db.First(&user, challenge.UserID).Error; err != nil || !user.TwoFactorEnabled {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": errInvalidMFAToken.Error()})
+		return
+	}
+
+	ok, err := verifySecondFactor(user, req.Code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid code"})
+		return
+	}
+
+	// The challenge is done; a second request with it must not open
+	// another session
+	if result := db.Delete(&challenge); result.Error != nil || result.RowsAffected == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": errInvalidMFAToken.Error()})
+		return
+	}
+
+	response, err := openUserSession(c, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// getTwoFactorStatus tells whether the current user has two-factor
+// authentication on and how many recovery codes they have left.
+func getTwoFactorStatus(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var user User
+	if err := db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	var remaining int64
+	if err := db.Model(&RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", user.ID).Count(&remaining).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch two-factor status"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":                  user.TwoFactorEnabled,
+		"recovery_codes_remaining": remaining,
+	})
+}
+
+// setupTwoFactor starts enrollment with a new secret. Two-factor
+// authentication is only on once enableTwoFactor confirms a code from it.
+func setupTwoFactor(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var req TwoFactorSetupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user User
+	if err := db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if user.TwoFactorEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+	if !checkPassword(req.Password, user.Password) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password is incorrect"})
+		return
+	}
+
+	secret, err := newTOTPSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+		return
+	}
+	if err := db.Model(&user).Updates(map[string]interface{}{"totp_secret": secret, "totp_last_step": 0}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start two-factor setup"})
+		return
+	}
+
+	c.JSON(http.StatusOK, TwoFactorSetupResponse{Secret: secret, OTPAuthURI: totpURI(secret, user.Email)})
+}
+
+// enableTwoFactor turns two-factor authentication on once the user proves
+// their authenticator works, and returns their recovery codes.
+func enableTwoFactor(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user User
+	if err := db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if user.TwoFactorEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+	if user.TOTPSecret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Start two-factor setup first"})
+		return
+	}
+
+	step, ok := matchTOTP(user.TOTPSecret, normalizeCode(req.Code), time.Now())
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid code"})
+		return
+	}
+
+	var codes []string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(map[string]interface{}{"two_factor_enabled": true, "totp_last_step": step}).Error; err != nil {
+			return err
+		}
+		var err error
+		codes, err = newRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// disableTwoFactor turns two-factor authentication off, which takes the
+// password and a current code. Admins cannot while it is required for them.
+func disableTwoFactor(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var req TwoFactorDisableRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user User
+	if err := db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if !user.TwoFactorEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+
+	if user.Role == "admin" {
+		settings, err := securitySettings()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch security settings"})
+			return
+		}
+		if settings.RequireAdminTwoFactor {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication is required for admins"})
+			return
+		}
+	}
+
+	if !checkPassword(req.Password, user.Password) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password is incorrect"})
+		return
+	}
+	ok, err := verifySecondFactor(user, req.Code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid code"})
+		return
+	}
+
+	if err := clearTwoFactor(db, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// regenerateRecoveryCodes replaces the current user's recovery codes, for
+// when they run low or may have been seen.
+func regenerateRecoveryCodes(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user User
+	if err := db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if !user.TwoFactorEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+
+	ok, err := verifySecondFactor(user, req.Code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid code"})
+		return
+	}
+
+	var codes []string
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = newRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// clearTwoFactor turns two-factor authentication off for a user and
+// forgets their secret and recovery codes.
+func clearTwoFactor(tx *gorm.DB, userID uint) error {
+	return tx.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&User{}).Where("id = ?", userID).
+			Updates(map[string]interface{}{"two_factor_enabled": false, "totp_secret": "", "totp_last_step": 0}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&RecoveryCode{}).Error
+	})
+}
+
+// resetUserTwoFactor turns off two-factor authentication for a user who
+// lost both their authenticator and their recovery codes, and signs them
+// out everywhere. They can sign in with their password and enroll again.
+func resetUserTwoFactor(c *gin.Context) {
+	adminID, _ := c.Get("user_id")
+
+	var user User
+	if err := db.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	// Admins turn their own off with disableTwoFactor, which checks a code
+	if user.ID == adminID.(uint) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot reset your own two-factor authentication"})
+		return
+	}
+
+	// A reset may follow a suspected compromise, so every existing sign-in
+	// ends with it
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := clearTwoFactor(tx, user.ID); err != nil {
+			return err
+		}
+		if _, err := revokeSessions(tx, user.ID); err != nil {
+			return err
+		}
+		return invalidateTokens(tx, user.ID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset two-factor authentication"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication reset"})
+}
+
+func getSecuritySettings(c *gin.Context) {
+	settings, err := securitySettings()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch security settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+func updateSecuritySettings(c *gin.Context) {
+	twoFactor, _ := c.Get("two_factor_enabled")
+
+	var req SecuritySettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Requiring it without having it would lock the admin out of the
+	// settings they just changed
+	if *req.RequireAdminTwoFactor && twoFactor != true {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Enable two-factor authentication on your own account first"})
+		return
+	}
+
+	settings, err := securitySettings()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch security settings"})
+		return
+	}
+	settings.RequireAdminTwoFactor = *req.RequireAdminTwoFactor
+	if err := db.Save(&settings).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update security settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+// deleteExpiredMFAChallenges removes logins that were never completed.
+func deleteExpiredMFAChallenges(now time.Time) {
+	if err := db.Where("expires_at < ?", now).Delete(&MFAChallenge{}).Error; err != nil {
+		log.Printf("expire-mfa-challenges: %v", err)
+	}
+}
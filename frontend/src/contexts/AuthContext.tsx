@@ -1,14 +1,16 @@
 'use client';
 
 import React, { createContext, useContext, useEffect, useState } from 'react';
-import { User, LoginRequest, RegisterRequest } from '@/types';
-import { authAPI } from '@/lib/api';
+import { User, LoginRequest, RegisterRequest, AuthResponse, MFAChallengeResponse } from '@/types';
+import { authAPI, storeSession, clearSession } from '@/lib/api';
 import toast from 'react-hot-toast';
 
 interface AuthContextType {
   user: User | null;
   isLoading: boolean;
-  login: (data: LoginRequest) => Promise<void>;
+  // Resolves with the challenge when the account needs a second factor
+  login: (data: LoginRequest) => Promise<MFAChallengeResponse | void>;
+  verifyLogin: (mfaToken: string, code: string) => Promise<void>;
   register: (data: RegisterRequest) => Promise<void>;
   logout: () => void;
   updateUser: (user: User) => void;
@@ -35,8 +37,7 @@ export function AuthProvider({ children }: { children: React.ReactNode }) {
             localStorage.setItem('user', JSON.stringify(currentUser));
           } catch (error) {
             // Token might be invalid, clear auth
-            localStorage.removeItem('token');
-            localStorage.removeItem('user');
+            clearSession();
             setUser(null);
           }
         }
@@ -50,13 +51,19 @@ export function AuthProvider({ children }: { children: React.ReactNode }) {
     initAuth();
   }, []);
 
+  const completeLogin = (response: AuthResponse) => {
+    storeSession(response);
+    setUser(response.user);
+    toast.success('Login successful!');
+  };
+
   const login = async (data: LoginRequest) => {
     try {
       const response = await authAPI.login(data);
-      localStorage.setItem('token', response.token);
-      localStorage.setItem('user', JSON.stringify(response.user));
-      setUser(response.user);
-      toast.success('Login successful!');
+      if ('mfa_required' in response) {
+        return response;
+      }
+      completeLogin(response);
     } catch (error: any) {
       const message = error.response?.data?.error || 'Login failed';
       toast.error(message);
@@ -64,11 +71,20 @@ export function AuthProvider({ children }: { children: React.ReactNode }) {
     }
   };
 
+  const verifyLogin = async (mfaToken: string, code: string) => {
+    try {
+      completeLogin(await authAPI.verifyLogin(mfaToken, code));
+    } catch (error: any) {
+      const message = error.response?.data?.error || 'Verification failed';
+      toast.error(message);
+      throw error;
+    }
+  };
+
   const register = async (data: RegisterRequest) => {
     try {
       const response = await authAPI.register(data);
-      localStorage.setItem('token', response.token);
-      localStorage.setItem('user', JSON.stringify(response.user));
+      storeSession(response);
       setUser(response.user);
       toast.success('Registration successful!');
     } catch (error: any) {
@@ -79,8 +95,12 @@ export function AuthProvider({ children }: { children: React.ReactNode }) {
   };
 
   const logout = () => {
-    localStorage.removeItem('token');
-    localStorage.removeItem('user');
+    // End the session on the server too; signing out locally does not wait for it
+    const refreshToken = localStorage.getItem('refreshToken');
+    if (refreshToken) {
+      authAPI.logout(refreshToken).catch(() => {});
+    }
+    clearSession();
     setUser(null);
     toast.success('Logged out successfully');
   };
@@ -96,6 +116,7 @@ export function AuthProvider({ children }: { children: React.ReactNode }) {
         user,
         isLoading,
         login,
+        verifyLogin,
         register,
         logout,
         updateUser,
@@ -1,4 +1,4 @@
-import axios from 'axios';
+import axios, { InternalAxiosRequestConfig } from 'axios';
 import {
   User,
   Attendance,
@@ -11,6 +11,11 @@ import {
   UpdateProfileRequest,
   UpdateUserRequest,
   PaginationResponse,
+  UserSession,
+  MFAChallengeResponse,
+  TwoFactorStatus,
+  TwoFactorSetupResponse,
+  SecuritySettings,
 } from '@/types';
 
 const API_BASE_URL = process.env.NEXT_PUBLIC_API_URL || 'http://localhost:8080/api';
@@ -23,6 +28,19 @@ const api = axios.create({
   },
 });
 
+// Session storage: a short-lived access token and the refresh token that renews it
+export const storeSession = (response: AuthResponse) => {
+  localStorage.setItem('token', response.token);
+  localStorage.setItem('refreshToken', response.refresh_token);
+  localStorage.setItem('user', JSON.stringify(response.user));
+};
+
+export const clearSession = () => {
+  localStorage.removeItem('token');
+  localStorage.removeItem('refreshToken');
+  localStorage.removeItem('user');
+};
+
 // Request interceptor to add auth token
 api.interceptors.request.use((config) => {
   const token = localStorage.getItem('token');
@@ -32,13 +50,48 @@ api.interceptors.request.use((config) => {
   return config;
 });
 
-// Response interceptor to handle auth errors
+// Concurrent requests failing with 401 share one refresh
+let refreshPromise: Promise<string> | null = null;
+
+const refreshAccessToken = (): Promise<string> => {
+  if (!refreshPromise) {
+    const refreshToken = localStorage.getItem('refreshToken');
+    refreshPromise = (
+      refreshToken
+        ? axios
+            .post<AuthResponse>(`${API_BASE_URL}/auth/refresh`, { refresh_token: refreshToken })
+            .then((res) => {
+              storeSession(res.data);
+              return res.data.token;
+            })
+        : Promise.reject(new Error('No refresh token'))
+    ).finally(() => {
+      refreshPromise = null;
+    });
+  }
+  return refreshPromise;
+};
+
+// Response interceptor to handle auth errors: renew an expired access token
+// once, and sign out if that fails
 api.interceptors.response.use(
   (response) => response,
-  (error) => {
-    if (error.response?.status === 401) {
-      localStorage.removeItem('token');
-      localStorage.removeItem('user');
+  async (error) => {
+    const original = error.config as (InternalAxiosRequestConfig & { _retry?: boolean }) | undefined;
+    // A rejected login or second factor is shown on the login page, which
+    // keeps the pending two-factor challenge
+    if (error.response?.status === 401 && !original?.url?.startsWith('/auth/login')) {
+      if (original && !original._retry && !original.url?.startsWith('/auth/')) {
+        original._retry = true;
+        try {
+          const token = await refreshAccessToken();
+          original.headers.Authorization = `Bearer ${token}`;
+          return api(original);
+        } catch {
+          // Fall through to signing out
+        }
+      }
+      clearSession();
       window.location.href = '/auth/login';
     }
     return Promise.reject(error);
@@ -47,17 +100,60 @@ api.interceptors.response.use(
 
 // Auth API
 export const authAPI = {
-  login: (data: LoginRequest): Promise<AuthResponse> =>
+  login: (data: LoginRequest): Promise<AuthResponse | MFAChallengeResponse> =>
     api.post('/auth/login', data).then((res) => res.data),
+
+  // Second step of a login that answered with mfa_required
+  verifyLogin: (mfaToken: string, code: string): Promise<AuthResponse> =>
+    api.post('/auth/login/verify', { mfa_token: mfaToken, code }).then((res) => res.data),
   
   register: (data: RegisterRequest): Promise<AuthResponse> =>
     api.post('/auth/register', data).then((res) => res.data),
+
+  refresh: (refreshToken: string): Promise<AuthResponse> =>
+    api.post('/auth/refresh', { refresh_token: refreshToken }).then((res) => res.data),
+
+  logout: (refreshToken: string): Promise<{ message: string }> =>
+    api.post('/auth/logout', { refresh_token: refreshToken }).then((res) => res.data),
+
+  forgotPassword: (email: string): Promise<{ message: string }> =>
+    api.post('/auth/forgot-password', { email }).then((res) => res.data),
+
+  resetPassword: (token: string, password: string): Promise<{ message: string }> =>
+    api.post('/auth/reset-password', { token, password }).then((res) => res.data),
   
   getProfile: (): Promise<User> =>
     api.get('/profile').then((res) => res.data),
   
   updateProfile: (data: UpdateProfileRequest): Promise<User> =>
     api.put('/profile', data).then((res) => res.data),
+
+  // Signs out other sessions; the response carries this session's new tokens
+  changePassword: (currentPassword: string, newPassword: string): Promise<AuthResponse> =>
+    api
+      .put('/profile/password', { current_password: currentPassword, new_password: newPassword })
+      .then((res) => {
+        storeSession(res.data);
+        return res.data;
+      }),
+};
+
+// Two-factor authentication API
+export const twoFactorAPI = {
+  getStatus: (): Promise<TwoFactorStatus> =>
+    api.get('/profile/2fa').then((res) => res.data),
+
+  setup: (password: string): Promise<TwoFactorSetupResponse> =>
+    api.post('/profile/2fa/setup', { password }).then((res) => res.data),
+
+  enable: (code: string): Promise<{ recovery_codes: string[] }> =>
+    api.post('/profile/2fa/enable', { code }).then((res) => res.data),
+
+  disable: (password: string, code: string): Promise<{ message: string }> =>
+    api.post('/profile/2fa/disable', { password, code }).then((res) => res.data),
+
+  regenerateRecoveryCodes: (code: string): Promise<{ recovery_codes: string[] }> =>
+    api.post('/profile/2fa/recovery-codes', { code }).then((res) => res.data),
 };
 
 // Attendance API
@@ -102,6 +198,21 @@ export const adminAPI = {
   
   deleteUser: (userId: number): Promise<{ message: string }> =>
     api.delete(`/admin/users/${userId}`).then((res) => res.data),
+
+  getUserSessions: (userId: number): Promise<UserSession[]> =>
+    api.get(`/admin/users/${userId}/sessions`).then((res) => res.data),
+
+  revokeUserSessions: (userId: number): Promise<{ revoked: number }> =>
+    api.post(`/admin/users/${userId}/sessions/revoke`).then((res) => res.data),
+
+  resetUserTwoFactor: (userId: number): Promise<{ message: string }> =>
+    api.post(`/admin/users/${userId}/2fa/reset`).then((res) => res.data),
+
+  getSecuritySettings: (): Promise<SecuritySettings> =>
+    api.get('/admin/settings/security').then((res) => res.data),
+
+  updateSecuritySettings: (data: { require_admin_two_factor: boolean }): Promise<SecuritySettings> =>
+    api.put('/admin/settings/security', data).then((res) => res.data),
 };
 
 export default api;
\ No newline at end of file
@@ -2,9 +2,11 @@ export interface User {
   id: number;
   email: string;
   name: string;
-  role: 'employee' | 'admin';
+  role: 'employee' | 'manager' | 'admin';
   position?: string;
   department?: string;
+  timezone?: string;
+  two_factor_enabled: boolean;
   created_at: string;
   updated_at: string;
 }
@@ -18,16 +20,242 @@ export interface Attendance {
   check_out?: string;
   notes: string;
   status: 'present' | 'absent' | 'late' | 'half_day' | 'not_checked_in';
+  worked_minutes: number;
+  break_minutes: number;
+  holiday_work: boolean;
+  auto_closed: boolean;
+  needs_review: boolean;
+  scheduled_minutes: number;
+  overtime_minutes: number;
+  overtime_multiplier: number;
+  overtime_status: '' | 'pending' | 'approved' | 'rejected';
+  overtime_reviewed_by?: number;
+  overtime_reviewed_at?: string;
+  sessions?: AttendanceSession[];
+  created_at: string;
+  updated_at: string;
+}
+
+export interface AttendanceSession {
+  id: number;
+  attendance_id: number;
+  type: 'work' | 'break';
+  started_at: string;
+  ended_at?: string;
+}
+
+export interface AttendanceSnapshot {
+  date: string;
+  check_in?: string;
+  check_out?: string;
+  status: string;
+  notes: string;
+  worked_minutes: number;
+  break_minutes: number;
+}
+
+export interface AttendanceAuditLog {
+  id: number;
+  attendance_id: number;
+  actor_id?: number;
+  actor?: User;
+  action: 'create' | 'update' | 'delete' | 'correction' | 'auto_checkout';
+  reason: string;
+  before?: AttendanceSnapshot;
+  after?: AttendanceSnapshot;
+  created_at: string;
+}
+
+export interface CreateAttendanceRequest {
+  user_id: number;
+  date: string;
+  check_in?: string;
+  check_out?: string;
+  notes?: string;
+  reason?: string;
+}
+
+export interface UpdateAttendanceRequest {
+  date?: string;
+  check_in?: string;
+  check_out?: string;
+  notes?: string;
+  reason?: string;
+}
+
+export interface CorrectionRequest {
+  id: number;
+  user_id: number;
+  user?: User;
+  attendance_id?: number;
+  date: string;
+  proposed_check_in?: string;
+  proposed_check_out?: string;
+  proposed_status: string;
+  proposed_notes?: string;
+  reason: string;
+  status: 'pending' | 'approved' | 'rejected' | 'cancelled';
+  reviewed_by?: number;
+  reviewed_at?: string;
+  review_note: string;
+  before?: AttendanceSnapshot;
+  after?: AttendanceSnapshot;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface CreateCorrectionRequest {
+  date: string;
+  check_in?: string;
+  check_out?: string;
+  status?: 'present' | 'absent' | 'late' | 'half_day';
+  notes?: string;
+  reason: string;
+}
+
+export interface LeaveType {
+  id: number;
+  code: string;
+  name: string;
+  paid: boolean;
+}
+
+export interface LeaveRequest {
+  id: number;
+  user_id: number;
+  leave_type_id: number;
+  leave_type?: LeaveType;
+  start_date: string;
+  end_date: string;
+  half_day: boolean;
+  days: number;
+  reason: string;
+  status: 'pending' | 'approved' | 'rejected' | 'cancelled';
+  review_note: string;
+  created_at: string;
+}
+
+export interface Timesheet {
+  id: number;
+  user_id: number;
+  user?: User;
+  period: 'weekly' | 'monthly';
+  start_date: string;
+  end_date: string;
+  status: 'draft' | 'submitted' | 'approved' | 'rejected';
+  worked_minutes: number;
+  scheduled_minutes: number;
+  overtime_minutes: number;
+  leave_days: number;
+  absent_days: number;
+  submitted_at?: string;
+  reviewed_by?: number;
+  reviewed_at?: string;
+  review_note: string;
+  reopened_by?: number;
+  reopened_at?: string;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface TimesheetDetail extends Timesheet {
+  attendance: Attendance[];
+  leave: LeaveRequest[];
+}
+
+export interface Notification {
+  id: number;
+  user_id: number;
+  type: 'auto_checkout' | 'correction_reviewed' | 'overtime_reviewed' | 'timesheet_reviewed';
+  title: string;
+  message: string;
+  attendance_id?: number;
+  read_at?: string;
+  created_at: string;
+}
+
+export interface Alert {
+  id: number;
+  user_id: number;
+  user: User;
+  rule: 'late_frequency' | 'monday_absences' | 'friday_absences' | 'check_in_drift';
+  message: string;
+  window_start: string;
+  window_end: string;
+  occurrences: number;
+  status: 'open' | 'acknowledged';
+  acknowledged_by?: number;
+  acknowledged_at?: string;
+  acknowledge_note: string;
   created_at: string;
   updated_at: string;
 }
 
 export interface AttendanceStats {
   total_days: number;
+  attended_days: number;
   present_days: number;
   absent_days: number;
   late_days: number;
+  half_day_days: number;
+  leave_days: number;
+  holiday_days: number;
+  holiday_work_days: number;
+  total_worked_hours: number;
+  overtime_hours: number;
+  average_check_in: string | null;
+  average_check_out: string | null;
+  current_on_time_streak: number;
+  longest_on_time_streak: number;
+  attendance_rate: number;
+}
+
+export interface AnalyticsRow {
+  period: string;
+  department?: string;
+  employees: number;
+  records: number;
+  attended_days: number;
+  late_days: number;
+  half_day_days: number;
+  absent_days: number;
+  attendance_rate: number;
+  late_rate: number;
+  average_check_in: string | null;
+}
+
+export interface AnalyticsResponse {
+  start_date: string;
+  end_date: string;
+  group_by: 'day' | 'week' | 'month';
+  data: AnalyticsRow[];
+}
+
+export interface HeatmapDay {
+  date: string;
+  present: number;
+  late: number;
+  half_day: number;
+  absent: number;
+  total: number;
+}
+
+export interface HistogramBucket {
+  start: string;
+  count: number;
+}
+
+export interface TrendWeek {
+  week: string;
+  records: number;
+  attended_days: number;
+  late_days: number;
+  absent_days: number;
+  worked_hours: number;
   attendance_rate: number;
+  late_rate: number;
+  attendance_rate_change: number | null;
+  late_rate_change: number | null;
 }
 
 export interface LoginRequest {
@@ -41,13 +269,50 @@ export interface RegisterRequest {
   password: string;
   position?: string;
   department?: string;
+  timezone?: string;
 }
 
 export interface AuthResponse {
   token: string;
+  expires_at: string;
+  refresh_token: string;
   user: User;
 }
 
+// Login answer for users with two-factor authentication; the code goes to
+// /auth/login/verify with mfa_token
+export interface MFAChallengeResponse {
+  mfa_required: true;
+  mfa_token: string;
+  expires_at: string;
+}
+
+export interface TwoFactorStatus {
+  enabled: boolean;
+  recovery_codes_remaining: number;
+}
+
+export interface TwoFactorSetupResponse {
+  secret: string;
+  otpauth_uri: string;
+}
+
+export interface SecuritySettings {
+  require_admin_two_factor: boolean;
+  updated_at: string;
+}
+
+export interface UserSession {
+  id: number;
+  user_id: number;
+  user_agent: string;
+  ip_address: string;
+  last_used_at: string;
+  expires_at: string;
+  revoked_at?: string;
+  created_at: string;
+}
+
 export interface CheckInRequest {
   notes?: string;
 }
@@ -60,10 +325,11 @@ export interface UpdateProfileRequest {
   name?: string;
   position?: string;
   department?: string;
+  timezone?: string;
 }
 
 export interface UpdateUserRequest extends UpdateProfileRequest {
-  role?: 'employee' | 'admin';
+  role?: 'employee' | 'manager' | 'admin';
 }
 
 export interface PaginationResponse<T> {